@@ -0,0 +1,289 @@
+package assert
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cahier/store"
+)
+
+// Result is what the assertions of a command are checked against
+type Result struct {
+	Output   string
+	Duration time.Duration
+	Golden   *string // Output of the golden run, nil if none is pinned
+}
+
+// Parse reads assertions separated by semicolons, each being one of:
+//
+//	contains <text>
+//	matches <regexp>
+//	golden
+//	json <path> == <value>
+//	under <duration>
+//
+// Text and values may be double quoted to keep surrounding spaces or semicolons.
+func Parse(s string) ([]store.Assertion, error) {
+	assertions := []store.Assertion{}
+	for _, part := range splitUnquoted(s, ';') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kind, arg, _ := strings.Cut(part, " ")
+		arg = strings.TrimSpace(arg)
+		assertion := store.Assertion{Kind: kind}
+
+		switch kind {
+		case store.AssertContains, store.AssertMatches:
+			value, err := unquote(arg)
+			if err != nil {
+				return nil, err
+			}
+			if value == "" {
+				return nil, fmt.Errorf("%s needs a value", kind)
+			}
+			if kind == store.AssertMatches {
+				if _, err := regexp.Compile(value); err != nil {
+					return nil, fmt.Errorf("invalid regexp %q: %v", value, err)
+				}
+			}
+			assertion.Value = value
+
+		case store.AssertGolden:
+			if arg != "" {
+				return nil, fmt.Errorf("golden takes no value")
+			}
+
+		case store.AssertJSON:
+			path, value, ok := strings.Cut(arg, "==")
+			if !ok {
+				return nil, fmt.Errorf("expected json <path> == <value>")
+			}
+			assertion.Path = strings.TrimSpace(path)
+			if _, err := parsePath(assertion.Path); err != nil {
+				return nil, err
+			}
+			assertion.Value = strings.TrimSpace(value)
+
+		case store.AssertUnder:
+			if _, err := parseDuration(arg); err != nil {
+				return nil, err
+			}
+			assertion.Value = arg
+
+		default:
+			return nil, fmt.Errorf("unknown assertion %q, expected contains, matches, golden, json or under", kind)
+		}
+
+		assertions = append(assertions, assertion)
+	}
+
+	return assertions, nil
+}
+
+// Format writes assertions back in the syntax read by Parse
+func Format(assertions []store.Assertion) string {
+	parts := make([]string, len(assertions))
+	for i, a := range assertions {
+		switch a.Kind {
+		case store.AssertContains, store.AssertMatches:
+			parts[i] = a.Kind + " " + quote(a.Value)
+		case store.AssertJSON:
+			parts[i] = a.Kind + " " + a.Path + " == " + a.Value
+		case store.AssertGolden:
+			parts[i] = a.Kind
+		default:
+			parts[i] = a.Kind + " " + a.Value
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Evaluate checks every assertion and explains the ones that failed
+func Evaluate(assertions []store.Assertion, result Result) []string {
+	failures := []string{}
+	for _, a := range assertions {
+		if failure := check(a, result); failure != "" {
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+func check(a store.Assertion, result Result) string {
+	switch a.Kind {
+	case store.AssertContains:
+		if !strings.Contains(result.Output, a.Value) {
+			return fmt.Sprintf("output does not contain %q", a.Value)
+		}
+
+	case store.AssertMatches:
+		re, err := regexp.Compile(a.Value)
+		if err != nil {
+			return fmt.Sprintf("invalid regexp %q: %v", a.Value, err)
+		}
+		if !re.MatchString(result.Output) {
+			return fmt.Sprintf("output does not match /%s/", a.Value)
+		}
+
+	case store.AssertGolden:
+		if result.Golden == nil {
+			return "no golden run is pinned"
+		}
+		if result.Output != *result.Golden {
+			return "output differs from the golden run"
+		}
+
+	case store.AssertJSON:
+		var doc any
+		if err := json.Unmarshal([]byte(result.Output), &doc); err != nil {
+			return fmt.Sprintf("output is not JSON: %v", err)
+		}
+		path, err := parsePath(a.Path)
+		if err != nil {
+			return err.Error()
+		}
+		actual, ok := lookup(doc, path)
+		if !ok {
+			return fmt.Sprintf("%s not found in output", a.Path)
+		}
+		if expected := parseValue(a.Value); !reflect.DeepEqual(actual, expected) {
+			got, _ := json.Marshal(actual)
+			return fmt.Sprintf("%s is %s, expected %s", a.Path, got, a.Value)
+		}
+
+	case store.AssertUnder:
+		limit, err := parseDuration(a.Value)
+		if err != nil {
+			return err.Error()
+		}
+		if result.Duration >= limit {
+			return fmt.Sprintf("took %s, expected under %s", result.Duration.Round(time.Millisecond), limit)
+		}
+
+	default:
+		return fmt.Sprintf("unknown assertion %q", a.Kind)
+	}
+
+	return ""
+}
+
+// parseDuration accepts Go durations like 1m30s or a number of seconds
+func parseDuration(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected seconds or a value like 1m30s", s)
+	}
+	return d, nil
+}
+
+// parseValue reads an expected JSON value, falling back to a plain string so
+// that `json .status == ok` works without quotes
+func parseValue(s string) any {
+	var value any
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+	return value
+}
+
+// parsePath splits a path like .items[0].name into its keys and indexes
+func parsePath(path string) ([]any, error) {
+	if !strings.HasPrefix(path, ".") {
+		return nil, fmt.Errorf("invalid JSON path %q, expected something like .items[0].name", path)
+	}
+
+	steps := []any{}
+	for _, part := range strings.Split(path[1:], ".") {
+		if part == "" {
+			continue
+		}
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			steps = append(steps, key)
+		}
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			i, err := strconv.Atoi(index)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid index in JSON path %q", path)
+			}
+			steps = append(steps, i)
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return steps, nil
+}
+
+func lookup(doc any, path []any) (any, bool) {
+	for _, step := range path {
+		switch step := step.(type) {
+		case string:
+			object, ok := doc.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = object[step]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := doc.([]any)
+			if !ok || step < 0 || step >= len(array) {
+				return nil, false
+			}
+			doc = array[step]
+		}
+	}
+	return doc, true
+}
+
+func quote(s string) string {
+	if s != strings.TrimSpace(s) || strings.ContainsAny(s, `;"`) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		value, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", s)
+		}
+		return value, nil
+	}
+	return s, nil
+}
+
+// splitUnquoted splits s on sep outside of double quoted strings
+func splitUnquoted(s string, sep rune) []string {
+	parts := []string{}
+	var current strings.Builder
+	quoted, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	return append(parts, current.String())
+}
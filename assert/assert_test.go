@@ -0,0 +1,101 @@
+package assert
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cahier/store"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []store.Assertion
+		wantErr bool
+	}{
+		{"empty", " ; ", []store.Assertion{}, false},
+		{"contains", "contains all good", []store.Assertion{{Kind: store.AssertContains, Value: "all good"}}, false},
+		{"quoted", `contains " a;b "`, []store.Assertion{{Kind: store.AssertContains, Value: " a;b "}}, false},
+		{"matches", `matches ^ok \d+$`, []store.Assertion{{Kind: store.AssertMatches, Value: `^ok \d+$`}}, false},
+		{"several", "golden; under 1m30s;json .items[0].name == \"a\"", []store.Assertion{
+			{Kind: store.AssertGolden},
+			{Kind: store.AssertUnder, Value: "1m30s"},
+			{Kind: store.AssertJSON, Path: ".items[0].name", Value: `"a"`},
+		}, false},
+		{"seconds", "under 2.5", []store.Assertion{{Kind: store.AssertUnder, Value: "2.5"}}, false},
+		{"unknown", "equals 3", nil, true},
+		{"missing value", "contains", nil, true},
+		{"invalid regexp", "matches (", nil, true},
+		{"bad quotes", `contains "a`, nil, true},
+		{"golden value", "golden yes", nil, true},
+		{"json without value", "json .a", nil, true},
+		{"invalid path", "json a == 1", nil, true},
+		{"invalid index", "json .a[x] == 1", nil, true},
+		{"invalid duration", "under soon", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+			if err == nil {
+				again, err := Parse(Format(got))
+				if err != nil || !reflect.DeepEqual(again, got) {
+					t.Errorf("Parse(Format(%+v)) = %+v, %v", got, again, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	golden := "ok\n"
+	output := `{"status": "ok", "items": [{"name": "a", "count": 2}]}`
+	tests := []struct {
+		name   string
+		input  string
+		result Result
+		want   []string
+	}{
+		{"contains", "contains status", Result{Output: output}, []string{}},
+		{"does not contain", "contains error", Result{Output: output},
+			[]string{`output does not contain "error"`}},
+		{"matches", `matches count": \d`, Result{Output: output}, []string{}},
+		{"does not match", `matches ^ok$`, Result{Output: output},
+			[]string{"output does not match /^ok$/"}},
+		{"golden", "golden", Result{Output: golden, Golden: &golden}, []string{}},
+		{"differs from golden", "golden", Result{Output: "ko\n", Golden: &golden},
+			[]string{"output differs from the golden run"}},
+		{"no golden", "golden", Result{Output: golden}, []string{"no golden run is pinned"}},
+		{"json string", "json .status == ok", Result{Output: output}, []string{}},
+		{"json number", "json .items[0].count == 2", Result{Output: output}, []string{}},
+		{"json differs", `json .items[0].name == "b"`, Result{Output: output},
+			[]string{`.items[0].name is "a", expected "b"`}},
+		{"json missing", "json .items[1].name == a", Result{Output: output},
+			[]string{".items[1].name not found in output"}},
+		{"not json", "json .status == ok", Result{Output: golden},
+			[]string{"output is not JSON: invalid character 'o' looking for beginning of value"}},
+		{"under", "under 1", Result{Duration: 500 * time.Millisecond}, []string{}},
+		{"too slow", "under 1", Result{Duration: 1500 * time.Millisecond},
+			[]string{"took 1.5s, expected under 1s"}},
+		{"all failures", "contains error; under 1s", Result{Output: output, Duration: time.Minute},
+			[]string{`output does not contain "error"`, "took 1m0s, expected under 1s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertions, err := Parse(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Evaluate(assertions, tt.result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
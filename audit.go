@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"cahier/store"
+)
+
+// profileEnv names the environment profile the commands run with, such as
+// staging or prod, for the audit log
+const profileEnv = "CAHIER_PROFILE"
+
+// newAuditEntry describes who acts on a command and from where
+func newAuditEntry(kind string, cmdID int64, command string) store.AuditEntry {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	dir, _ := os.Getwd()
+
+	return store.AuditEntry{
+		Kind:      kind,
+		CommandID: cmdID,
+		Command:   command,
+		User:      name,
+		Host:      host,
+		Dir:       dir,
+		Profile:   os.Getenv(profileEnv),
+	}
+}
+
+// auditConfirmation records that the current user confirmed running a guarded command
+func auditConfirmation(db *store.Store, cmd store.Command, reasons []string, how string) {
+	entry := newAuditEntry(store.AuditConfirm, cmd.ID, cmd.Command)
+	entry.Detail = how + ", matched " + strings.Join(reasons, ", ")
+	if err := db.AddAuditEntry(entry); err != nil {
+		log.Printf("Failed to save audit entry: %v", err)
+	}
+}
+
+// auditRun records a run along with the exact text that ran
+func auditRun(db *store.Store, runID int64, run store.Run, command string) {
+	entry := newAuditEntry(store.AuditRun, run.CommandID, command)
+	entry.CreatedAt = run.StartedAt
+	entry.RunID = runID
+	entry.Detail = fmt.Sprintf("exit %d in %s", run.ExitCode, run.Duration.Round(time.Millisecond))
+	if err := db.AddAuditEntry(entry); err != nil {
+		log.Printf("Failed to save audit entry: %v", err)
+	}
+}
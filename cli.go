@@ -0,0 +1,696 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cahier/config"
+	"cahier/diff"
+	"cahier/executor"
+	"cahier/guard"
+	"cahier/snapshot"
+	"cahier/store"
+)
+
+const usage = `Usage: cahier [command]
+
+Without a command, cahier opens the notebook interface.
+
+Commands:
+  search <query>    Search command text and stored outputs
+  run [--tag name]  Run every cell, or only the ones carrying all the tags
+  diff <cell>       Compare the output of the latest run of a cell with a previous one
+  test [notebook]   Run every cell and compare outputs with their snapshots
+  export <cell>     Print the output of a cell, one stream only or as JSON lines
+  guard             List or change the rules asking a confirmation before running a cell
+  audit             List, filter, verify or export the log of everything that ran
+  gc                Remove the runs beyond the notebook retention and compact it
+`
+
+// runCLI dispatches the command line subcommands
+func runCLI(db *store.Store, cfg config.Config, args []string) error {
+	switch args[0] {
+	case "search":
+		return runSearch(db, os.Stdout, args[1:])
+	case "run":
+		return runCells(db, cfg, os.Stdout, args[1:])
+	case "diff":
+		return runDiff(db, os.Stdout, args[1:])
+	case "test":
+		return runTest(db, cfg, os.Stdout, args[1:])
+	case "export":
+		return runExport(db, os.Stdout, args[1:])
+	case "guard":
+		return runGuard(db, os.Stdout, args[1:])
+	case "audit":
+		return runAudit(db, os.Stdout, args[1:])
+	case "gc":
+		return runGC(db, os.Stdout, args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command")
+}
+
+// openNotebook opens the notebook database at path, or named name.db
+func openNotebook(path string) (*store.Store, error) {
+	if _, err := os.Stat(path); err != nil && filepath.Ext(path) != ".db" {
+		path += ".db"
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no notebook at %s", path)
+	}
+
+	db := &store.Store{}
+	if err := db.Init(path); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// parseArgs parses the flags found before or after the positional arguments,
+// which flag.FlagSet alone stops at, and returns the positional ones
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// commandAt returns the command of a 1-based cell number given on the command line
+func commandAt(db *store.Store, cell string) (store.Command, error) {
+	cmds, err := db.GetCommands()
+	if err != nil {
+		return store.Command{}, err
+	}
+
+	number, err := strconv.Atoi(cell)
+	if err != nil || number < 1 || number > len(cmds) {
+		return store.Command{}, fmt.Errorf("no cell %q, the notebook has %d cells", cell, len(cmds))
+	}
+	return cmds[number-1], nil
+}
+
+// cellNumbers maps command IDs to their 1-based cell number in the notebook
+func cellNumbers(db *store.Store) (map[int64]int, error) {
+	cmds, err := db.GetCommands()
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make(map[int64]int, len(cmds))
+	for i, cmd := range cmds {
+		numbers[cmd.ID] = i + 1
+	}
+	return numbers, nil
+}
+
+func runSearch(db *store.Store, w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(args, " ")
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("missing search query")
+	}
+
+	warnSlowSearch(db)
+	results, err := db.Search(query)
+	if err != nil {
+		return err
+	}
+
+	numbers, err := cellNumbers(db)
+	if err != nil {
+		return err
+	}
+
+	terms := strings.Fields(query)
+	for _, result := range results {
+		// Deleted cells keep their runs until undone or pruned
+		number, ok := numbers[result.CommandID]
+		if !ok {
+			continue
+		}
+		line := store.MatchingLine(result.Text, terms)
+		fmt.Fprintf(w, "%d [%s]: %s\n", number, result.Source, line)
+	}
+
+	return nil
+}
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func runCells(db *store.Store, cfg config.Config, w io.Writer, args []string) error {
+	var tags stringList
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.Var(&tags, "tag", "only run the cells carrying this tag, can be repeated")
+	yes := flags.Bool("yes", false, "run the cells the guard rules ask a confirmation for, instead of skipping them")
+	if _, err := parseArgs(flags, args); err != nil {
+		return err
+	}
+
+	filter := store.Filter{}
+	for _, tag := range tags {
+		filter.Tags = append(filter.Tags, store.NormalizeTag(tag))
+	}
+
+	cmds, err := db.GetCommands()
+	if err != nil {
+		return err
+	}
+
+	settings := cfg.For(db.Name())
+	opts := execOptions(settings)
+	ran, failed, skipped, invalid := 0, 0, 0, 0
+	started := time.Now()
+	for i, cmd := range cmds {
+		if !filter.Matches(cmd) {
+			continue
+		}
+
+		fmt.Fprintf(w, "── %d: %s\n", i+1, cmd.Command)
+		if issue, found := checkBeforeRun(db, cmd.Command); found {
+			fmt.Fprintf(w, "── not run, %s\n\n", issue)
+			invalid++
+			continue
+		}
+		if reasons := guardReasons(db, cmd); len(reasons) > 0 {
+			if !*yes {
+				fmt.Fprintf(w, "── skipped, matched %s, run with --yes to confirm\n\n", strings.Join(reasons, ", "))
+				skipped++
+				continue
+			}
+			auditConfirmation(db, cmd, reasons, "confirmed with --yes")
+		}
+		run, status, detail := recordRun(db, cmd.ID, executor.ExecuteCommand(cmd.Command, opts))
+		notifyLongCLI(w, settings, db.Name(), i+1, run)
+		if run.Output != "" {
+			fmt.Fprintln(w, run.Output)
+		}
+		fmt.Fprintf(w, "── exit %d in %s\n", run.ExitCode, run.Duration.Round(time.Millisecond))
+		if detail != "" {
+			fmt.Fprintf(w, "── assertions failed:\n%s\n", detail)
+		}
+		fmt.Fprintln(w)
+
+		ran++
+		if status != store.StatusSuccess {
+			failed++
+		}
+	}
+
+	// Only a run of the whole notebook counts as the last run of every cell
+	if filter.IsZero() && ran > 0 {
+		runAll := store.RunAll{StartedAt: started, Duration: time.Since(started), Cells: ran, Failed: failed}
+		if err := db.SetLastRunAll(runAll); err != nil {
+			return err
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d cells not run, they have a syntax error", invalid)
+	}
+	if skipped > 0 {
+		return fmt.Errorf("%d cells skipped, they need a confirmation", skipped)
+	}
+	if ran == 0 && filter.IsZero() {
+		return fmt.Errorf("the notebook has no cells")
+	}
+	if ran == 0 {
+		return fmt.Errorf("no cell matches %s", filter)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cells failed", failed, ran)
+	}
+	return nil
+}
+
+func runDiff(db *store.Store, w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	against := flags.Int("run", 0, "compare with the Nth most recent run, 2 being the previous one")
+	golden := flags.Bool("golden", false, "compare with the golden run")
+	pin := flags.Bool("pin", false, "pin the latest run as the golden one")
+	context := flags.Int("context", 3, "lines of context around changes, -1 for all")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected a cell number")
+	}
+
+	cmd, err := commandAt(db, args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := loadOutputDiff(db, cmd.ID)
+	if err != nil {
+		return err
+	}
+	if len(d.runs) == 0 {
+		return fmt.Errorf("cell %s never ran", args[0])
+	}
+
+	if *pin {
+		if err := db.SetGoldenRun(cmd.ID, d.runs[0].ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Pinned the run from %s as golden\n", d.runs[0].StartedAt.Format("2006-01-02 15:04:05"))
+		return nil
+	}
+
+	switch {
+	case *against == 1:
+		return fmt.Errorf("run 1 is the latest run, compare with 2 or older")
+	case *against > 1:
+		d.against = *against - 1
+	case *golden:
+		if _, ok := d.goldenRun(); !ok {
+			return fmt.Errorf("cell %s has no golden run, pin one with --pin", args[0])
+		}
+		d.against = -1
+	}
+
+	fmt.Fprintln(w, d.header())
+	if _, _, ok := d.pair(); !ok {
+		return nil
+	}
+
+	lines := d.lines()
+	if !diff.Changed(lines) {
+		fmt.Fprintln(w, "Same output")
+		return nil
+	}
+	fmt.Fprintln(w, diff.Unified(lines, *context))
+	return nil
+}
+
+func runTest(db *store.Store, cfg config.Config, w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	update := flags.Bool("update", false, "accept the new outputs as snapshots")
+	yes := flags.Bool("yes", false, "run the cells the guard rules ask a confirmation for, instead of skipping them")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("expected a single notebook")
+	}
+	if len(args) == 1 {
+		if db, err = openNotebook(args[0]); err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	cmds, err := db.GetCommands()
+	if err != nil {
+		return err
+	}
+	if len(cmds) == 0 {
+		return fmt.Errorf("the notebook has no cells")
+	}
+
+	settings := cfg.For(db.Name())
+	opts := execOptions(settings)
+	passed, failed, missing, updated, skipped := 0, 0, 0, 0, 0
+	for i, cmd := range cmds {
+		title := fmt.Sprintf("%d: %s", i+1, strings.SplitN(cmd.Command, "\n", 2)[0])
+		if issue, found := checkBeforeRun(db, cmd.Command); found {
+			fmt.Fprintf(w, "%-6s %s\n", "FAIL", title)
+			fmt.Fprintf(w, "       not run, %s\n", issue)
+			failed++
+			continue
+		}
+		if reasons := guardReasons(db, cmd); len(reasons) > 0 {
+			if !*yes {
+				fmt.Fprintf(w, "%-6s %s\n", "SKIP", title)
+				fmt.Fprintf(w, "       matched %s, run with --yes to confirm\n", strings.Join(reasons, ", "))
+				skipped++
+				continue
+			}
+			auditConfirmation(db, cmd, reasons, "confirmed with --yes")
+		}
+		run, status, detail := recordRun(db, cmd.ID, executor.ExecuteCommand(cmd.Command, opts))
+		notifyLongCLI(w, settings, db.Name(), i+1, run)
+
+		normalizers, err := db.GetNormalizers(cmd.ID)
+		if err != nil {
+			return err
+		}
+		output := snapshot.Normalize(run.Output, normalizers)
+
+		expected, found, err := db.GetSnapshot(cmd.ID)
+		if err != nil {
+			return err
+		}
+
+		var problems []string
+		if found && expected.ExitCode != run.ExitCode {
+			problems = append(problems, fmt.Sprintf("exit code %d, snapshot has %d", run.ExitCode, expected.ExitCode))
+		}
+		if found {
+			lines := diff.Lines(snapshot.Normalize(expected.Output, normalizers), output)
+			if diff.Changed(lines) {
+				problems = append(problems, "output differs from snapshot:\n"+diff.Unified(lines, 3))
+			}
+		}
+
+		label := "PASS"
+		if *update && (!found || len(problems) > 0) {
+			if err := db.SaveSnapshot(store.Snapshot{CommandID: cmd.ID, Output: output, ExitCode: run.ExitCode}); err != nil {
+				return err
+			}
+			label, found, problems = "UPDATE", true, nil
+			updated++
+		}
+
+		// Accepting a snapshot does not make failed assertions pass
+		if status == store.StatusAssertionFailed {
+			problems = append([]string{"assertions failed:\n" + detail}, problems...)
+		}
+
+		switch {
+		case len(problems) > 0:
+			label = "FAIL"
+			failed++
+		case !found:
+			label = "MISS"
+			missing++
+		case label == "PASS":
+			passed++
+		}
+
+		fmt.Fprintf(w, "%-6s %s\n", label, title)
+		for _, problem := range problems {
+			fmt.Fprintln(w, indent(problem, "       "))
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d cells: %d passed, %d failed, %d without snapshot, %d updated, %d skipped\n",
+		len(cmds), passed, failed, missing, updated, skipped)
+	if missing > 0 {
+		fmt.Fprintln(w, "Run with --update to record the missing snapshots")
+	}
+	if skipped > 0 {
+		fmt.Fprintln(w, "Run with --yes to run the cells that need a confirmation")
+	}
+	if failed > 0 || missing > 0 {
+		return fmt.Errorf("%d of %d cells did not match their snapshot", failed+missing, len(cmds))
+	}
+	if skipped > 0 {
+		return fmt.Errorf("%d cells skipped, they need a confirmation", skipped)
+	}
+	return nil
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+func runExport(db *store.Store, w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	nth := flags.Int("run", 1, "export the Nth most recent run, 1 being the latest")
+	stream := flags.String("stream", "", "only export stdout or stderr")
+	jsonLines := flags.Bool("json", false, "print one JSON object per chunk, with its stream and time")
+	args, err := parseArgs(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected a cell number")
+	}
+	if *stream != "" && *stream != store.StreamStdout && *stream != store.StreamStderr {
+		return fmt.Errorf("unknown stream %q, expected stdout or stderr", *stream)
+	}
+
+	cmd, err := commandAt(db, args[0])
+	if err != nil {
+		return err
+	}
+	runs, err := db.GetRuns(cmd.ID)
+	if err != nil {
+		return err
+	}
+	if *nth < 1 || *nth > len(runs) {
+		return fmt.Errorf("cell %s has %d runs", args[0], len(runs))
+	}
+	run := runs[*nth-1]
+
+	if !*jsonLines {
+		if output := run.Stream(*stream); output != "" {
+			fmt.Fprintln(w, output)
+		}
+		return nil
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, chunk := range run.Chunks {
+		if *stream != "" && chunk.Stream != *stream {
+			continue
+		}
+		err := encoder.Encode(struct {
+			Stream string    `json:"stream"`
+			Time   time.Time `json:"time"`
+			Text   string    `json:"text"`
+		}{chunk.Stream, chunk.Time, chunk.Text})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runGC(db *store.Store, w io.Writer, args []string) error {
+	retention, err := db.GetRetention()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	keepRuns := flags.Int("keep-runs", retention.KeepRuns, "runs kept per cell, 0 for all, saved for the notebook")
+	maxMB := flags.Int("max-mb", retention.MaxMB, "size of the stored outputs in MB, 0 for no limit, saved for the notebook")
+	if _, err := parseArgs(flags, args); err != nil {
+		return err
+	}
+
+	updated := store.Retention{KeepRuns: *keepRuns, MaxMB: *maxMB}
+	if updated != retention {
+		if err := db.SetRetention(updated); err != nil {
+			return err
+		}
+	}
+
+	before, err := db.Size()
+	if err != nil {
+		return err
+	}
+	result, err := db.Prune(updated)
+	if err != nil {
+		return err
+	}
+	if err := db.Vacuum(); err != nil {
+		return err
+	}
+	after, err := db.Size()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Removed %d runs and %d blobs, %s → %s\n",
+		result.Runs, result.Blobs, formatSize(before), formatSize(after))
+	return nil
+}
+
+// formatSize prints a size in bytes with a binary unit
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
+
+func runGuard(db *store.Store, w io.Writer, args []string) error {
+	var add, remove, tag, untag stringList
+	flags := flag.NewFlagSet("guard", flag.ContinueOnError)
+	flags.Var(&add, "add", "add a pattern matched against the command text, can be repeated")
+	flags.Var(&remove, "remove", "remove a pattern, can be repeated")
+	flags.Var(&tag, "tag", "ask a confirmation for the cells carrying this tag, can be repeated")
+	flags.Var(&untag, "untag", "stop asking for the cells carrying this tag, can be repeated")
+	reset := flags.Bool("reset", false, "go back to the default rules")
+	if _, err := parseArgs(flags, args); err != nil {
+		return err
+	}
+
+	rules, err := loadGuardRules(db)
+	if err != nil {
+		return err
+	}
+	if *reset {
+		rules = guard.DefaultRules()
+	}
+	for _, pattern := range remove {
+		rules.Patterns = slices.DeleteFunc(rules.Patterns, func(p string) bool { return p == pattern })
+	}
+	rules.Patterns = append(rules.Patterns, add...)
+	for _, name := range untag {
+		rules.Tags = slices.DeleteFunc(rules.Tags, func(t string) bool { return t == store.NormalizeTag(name) })
+	}
+	for _, name := range tag {
+		if name := store.NormalizeTag(name); !slices.Contains(rules.Tags, name) {
+			rules.Tags = append(rules.Tags, name)
+		}
+	}
+
+	if *reset || len(add)+len(remove)+len(tag)+len(untag) > 0 {
+		if err := saveGuardRules(db, rules); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "Patterns:")
+	for _, pattern := range rules.Patterns {
+		fmt.Fprintf(w, "  %s\n", pattern)
+	}
+	fmt.Fprintln(w, "Tags:")
+	for _, name := range rules.Tags {
+		fmt.Fprintf(w, "  #%s\n", name)
+	}
+	return nil
+}
+
+func runAudit(db *store.Store, w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	kind := flags.String("kind", "", "only list the entries of this kind, run or confirm")
+	userName := flags.String("user", "", "only list the entries of this user")
+	cell := flags.String("cell", "", "only list the entries of this cell")
+	since := flags.String("since", "", "only list the entries from this date (2006-01-02) or this long ago (24h)")
+	jsonLines := flags.Bool("json", false, "export the entries as JSON lines")
+	verify := flags.Bool("verify", false, "check that no entry was changed or removed")
+	if _, err := parseArgs(flags, args); err != nil {
+		return err
+	}
+
+	if *verify {
+		broken, err := db.VerifyAudit()
+		if err != nil {
+			return err
+		}
+		if broken != 0 {
+			return fmt.Errorf("the audit log was tampered with at entry %d", broken)
+		}
+		fmt.Fprintln(w, "The audit log is intact")
+		return nil
+	}
+
+	filter := store.AuditFilter{Kind: *kind, User: *userName}
+	if *cell != "" {
+		cmd, err := commandAt(db, *cell)
+		if err != nil {
+			return err
+		}
+		filter.CommandID = cmd.ID
+	}
+	if *since != "" {
+		if ago, err := time.ParseDuration(*since); err == nil {
+			filter.Since = time.Now().Add(-ago)
+		} else if date, err := time.ParseInLocation("2006-01-02", *since, time.Local); err == nil {
+			filter.Since = date
+		} else {
+			return fmt.Errorf("invalid --since %q, expected a date such as 2006-01-02 or a duration such as 24h", *since)
+		}
+	}
+
+	entries, err := db.GetAuditEntries(filter)
+	if err != nil {
+		return err
+	}
+
+	if *jsonLines {
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			err := encoder.Encode(struct {
+				ID        int64     `json:"id"`
+				Time      time.Time `json:"time"`
+				Kind      string    `json:"kind"`
+				CommandID int64     `json:"command_id"`
+				RunID     int64     `json:"run_id,omitempty"`
+				Command   string    `json:"command"`
+				User      string    `json:"user"`
+				Host      string    `json:"host"`
+				Dir       string    `json:"dir"`
+				Profile   string    `json:"profile,omitempty"`
+				Detail    string    `json:"detail,omitempty"`
+				PrevHash  string    `json:"prev_hash"`
+				Hash      string    `json:"hash"`
+			}{entry.ID, entry.CreatedAt, entry.Kind, entry.CommandID, entry.RunID, entry.Command, entry.User,
+				entry.Host, entry.Dir, entry.Profile, entry.Detail, entry.PrevHash, entry.Hash})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	numbers, err := cellNumbers(db)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		cell := "deleted cell"
+		if number, ok := numbers[entry.CommandID]; ok {
+			cell = fmt.Sprintf("cell %d", number)
+		}
+		where := entry.User + "@" + entry.Host
+		if entry.Profile != "" {
+			where += " [" + entry.Profile + "]"
+		}
+		if entry.Dir != "" {
+			where += " in " + entry.Dir
+		}
+		fmt.Fprintf(w, "%s %-7s %s, %s: %s\n", entry.CreatedAt.Format("2006-01-02 15:04:05"),
+			entry.Kind, where, cell, strings.ReplaceAll(entry.Command, "\n", "⏎"))
+		if entry.Detail != "" {
+			fmt.Fprintf(w, "%27s%s\n", "", entry.Detail)
+		}
+	}
+	return nil
+}
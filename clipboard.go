@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// flashDuration is how long transient messages stay in the footer
+const flashDuration = 2 * time.Second
+
+type clipboardMsg struct {
+	what   string // What was copied, for the confirmation
+	escape string // OSC52 escape copying through the terminal, written with the view
+}
+
+type flashExpiredMsg struct {
+	id int
+}
+
+// copyToClipboard copies text with OSC52 over SSH, where the native clipboard
+// would be the one of the remote machine, and natively otherwise. Terminals
+// without a native clipboard tool fall back to OSC52 too.
+func copyToClipboard(text, what string) tea.Cmd {
+	return func() tea.Msg {
+		if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+			if err := clipboard.WriteAll(text); err == nil {
+				return clipboardMsg{what: what}
+			}
+		}
+
+		seq := osc52.New(text)
+		switch {
+		case os.Getenv("TMUX") != "":
+			seq = seq.Tmux()
+		case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+			seq = seq.Screen()
+		}
+		return clipboardMsg{what: what, escape: seq.String()}
+	}
+}
+
+// markdownSnippet formats a command and its output for pasting in Markdown documents
+func markdownSnippet(command, output string) string {
+	snippet := "```bash\n" + command + "\n```\n"
+	if output != "" {
+		snippet += "\n```\n" + output + "\n```\n"
+	}
+	return snippet
+}
+
+// copySelected copies the command of the selected cell, its last output or
+// both as a Markdown snippet
+func copySelected(m Model, what string) (Model, tea.Cmd) {
+	if m.currentIdx < 0 || m.currentIdx >= len(m.cmds) {
+		return m, nil
+	}
+	command := m.cmds[m.currentIdx].Command
+	output, ran := m.cmdsHistory.Output(m.currentIdx)
+
+	switch what {
+	case "command":
+		return m, copyToClipboard(command, what)
+	case "output":
+		if !ran {
+			return showFlash(m, fmt.Sprintf("Cell %d never ran", m.currentIdx+1))
+		}
+		return m, copyToClipboard(output, what)
+	default:
+		return m, copyToClipboard(markdownSnippet(command, output), what)
+	}
+}
+
+// showFlash shows a message in the footer for a short while
+func showFlash(m Model, message string) (Model, tea.Cmd) {
+	m.flashID++
+	m.flash = message
+	id := m.flashID
+	return m, tea.Tick(flashDuration, func(time.Time) tea.Msg {
+		return flashExpiredMsg{id: id}
+	})
+}
+
+// showError tells in the footer what failed, and keeps it in the log
+func showError(m Model, what string, err error) (Model, tea.Cmd) {
+	log.Printf("%s: %v", what, err)
+	return showFlash(m, errorStyle.Render(what+": "+err.Error()))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cahier/config"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestClipboardEscapeInView(t *testing.T) {
+	tests := []struct {
+		name   string
+		escape string
+	}{
+		{"native clipboard", ""},
+		{"osc52", "\x1b]52;c;ZGF0ZQ==\a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestNotebook(t, filepath.Join(t.TempDir(), "cells.db"), "date")
+			model, _ := NewModel(db, config.Default()).Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+
+			model, _ = model.Update(clipboardMsg{what: "command", escape: tt.escape})
+			m := model.(Model)
+			if !strings.Contains(m.flash, "Copied command") {
+				t.Errorf("flash %q, want the copy confirmation", m.flash)
+			}
+			if tt.escape != "" && !strings.Contains(m.View(), tt.escape) {
+				t.Error("the view is missing the escape")
+			}
+
+			model, _ = m.Update(escapeWrittenMsg{id: m.escapeID})
+			if view := model.(Model).View(); strings.Contains(view, "\x1b]52") {
+				t.Error("the escape stayed in the view once written")
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"cahier/keymap"
+	"cahier/notify"
+	"cahier/theme"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Duration is a time.Duration written as "30s" or "1m30s" in the file
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, expected a value such as 30s or 5m", text)
+	}
+	d.Duration = duration
+	return nil
+}
+
+type Output struct {
+	FoldLines int `toml:"fold_lines"` // Output lines shown by collapsed cells
+	MaxKB     int `toml:"max_kb"`     // Output kept per run, 0 for no limit
+}
+
+// Notify tells when a long cell finished, such as while looking at another window
+type Notify struct {
+	After   Duration `toml:"after"`   // Cells running at least this long notify when they finish, 0 for never
+	Method  string   `toml:"method"`  // bell, osc9, osc777 or command
+	Command string   `toml:"command"` // Hook run with the shell by the command method, see notify.Run
+}
+
+type Keys struct {
+	Preset   string              `toml:"preset"`   // default, vim or emacs
+	Bindings map[string][]string `toml:"bindings"` // Keys of an action such as "view.new", replacing the ones of the preset
+}
+
+// Config holds the user settings, read from config.toml
+type Config struct {
+	Database  string   `toml:"database"`  // Notebook opened at startup
+	Shell     string   `toml:"shell"`     // Shell running the commands with -c
+	Timeout   Duration `toml:"timeout"`   // Commands running longer are stopped, 0 for never
+	Theme     string   `toml:"theme"`     // Name of a built-in theme, or name or path of a theme file
+	Animation bool     `toml:"animation"` // Whether the selected cell border cycles colors
+	Tick      Duration `toml:"tick"`      // Interval of the animation
+	Mouse     bool     `toml:"mouse"`     // Whether clicks and the wheel go to cahier rather than selecting text
+	Split     bool     `toml:"split"`     // Whether the selected cell is detailed next to the cells on wide terminals
+	Output    Output   `toml:"output"`
+	Notify    Notify   `toml:"notify"`
+	Keys      Keys     `toml:"keys"`
+
+	// Settings of a notebook, by notebook name, replacing the ones above
+	Notebooks map[string]Notebook `toml:"notebooks"`
+
+	dir string // Directory of the config file, holding the theme files
+}
+
+// Notebook overrides the settings of one notebook. Unset fields keep the global value.
+type Notebook struct {
+	Shell       *string   `toml:"shell"`
+	Timeout     *Duration `toml:"timeout"`
+	Theme       *string   `toml:"theme"`
+	Animation   *bool     `toml:"animation"`
+	FoldLines   *int      `toml:"fold_lines"`
+	MaxKB       *int      `toml:"max_kb"`
+	NotifyAfter *Duration `toml:"notify_after"`
+}
+
+func Default() Config {
+	return Config{
+		Database:  "./cahier.db",
+		Shell:     "bash",
+		Theme:     "dark",
+		Animation: true,
+		Tick:      Duration{100 * time.Millisecond},
+		Mouse:     true,
+		Output:    Output{FoldLines: 10},
+		Notify:    Notify{Method: notify.Bell},
+		Keys:      Keys{Preset: "default"},
+		dir:       filepath.Dir(Path()),
+	}
+}
+
+// Path returns the location of the config file: $CAHIER_CONFIG, else
+// config.toml in the cahier directory of the XDG config directory
+func Path() string {
+	if path := os.Getenv("CAHIER_CONFIG"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "cahier", "config.toml")
+}
+
+// Load reads the config file on top of the defaults. A missing file leaves the defaults.
+func Load() (Config, error) {
+	return LoadFile(Path())
+}
+
+func LoadFile(path string) (Config, error) {
+	cfg := Default()
+	cfg.dir = filepath.Dir(path)
+
+	meta, err := toml.DecodeFile(path, &cfg)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		return cfg, fmt.Errorf("%s:%d: %s", path, parseErr.Position.Line, parseErr.Message)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+
+	problems := []string{}
+	for _, key := range meta.Undecoded() {
+		problems = append(problems, fmt.Sprintf("unknown setting %q", key.String()))
+	}
+	problems = append(problems, cfg.validate()...)
+	if _, err := keymap.New(cfg.Keys.Preset, cfg.Keys.Bindings); err != nil {
+		problems = append(problems, "keys: "+err.Error())
+	}
+	if len(problems) > 0 {
+		return cfg, fmt.Errorf("%s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+
+	cfg.Database = expandHome(cfg.Database)
+	return cfg, nil
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// validate returns the problems of the settings, global then per notebook
+func (c Config) validate() []string {
+	problems := c.validateValues("")
+
+	names := make([]string, 0, len(c.Notebooks))
+	for name := range c.Notebooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		problems = append(problems, c.For(name).validateValues("notebooks."+name+".")...)
+	}
+	return problems
+}
+
+func (c Config) validateValues(prefix string) []string {
+	problems := []string{}
+	if prefix == "" && c.Database == "" {
+		problems = append(problems, "database must not be empty")
+	}
+	if _, err := exec.LookPath(c.Shell); err != nil {
+		problems = append(problems, fmt.Sprintf("%sshell: %q not found", prefix, c.Shell))
+	}
+	if c.Timeout.Duration < 0 {
+		problems = append(problems, prefix+"timeout must not be negative")
+	}
+	if c.Tick.Duration < 10*time.Millisecond {
+		problems = append(problems, prefix+"tick must be at least 10ms")
+	}
+	if c.Output.FoldLines < 2 {
+		problems = append(problems, prefix+"fold_lines must be at least 2")
+	}
+	if _, err := c.LoadTheme(); err != nil {
+		problems = append(problems, prefix+"theme: "+err.Error())
+	}
+	if c.Output.MaxKB < 0 {
+		problems = append(problems, prefix+"max_kb must not be negative")
+	}
+	if c.Notify.After.Duration < 0 {
+		problems = append(problems, prefix+"notify.after must not be negative")
+	}
+	if prefix == "" && !slices.Contains(notify.Methods(), c.Notify.Method) {
+		problems = append(problems, fmt.Sprintf("notify.method: unknown method %q, expected one of %s", c.Notify.Method, strings.Join(notify.Methods(), ", ")))
+	}
+	if prefix == "" && c.Notify.Method == notify.Command && c.Notify.Command == "" {
+		problems = append(problems, "notify.command must not be empty with the command method")
+	}
+	return problems
+}
+
+// LoadTheme returns the theme of the settings, reading theme files from the
+// themes directory next to the config file
+func (c Config) LoadTheme() (theme.Theme, error) {
+	return theme.Load(c.Theme, filepath.Join(c.dir, "themes"))
+}
+
+// For returns the settings of a notebook, with its overrides applied
+func (c Config) For(notebook string) Config {
+	override, ok := c.Notebooks[notebook]
+	if !ok {
+		return c
+	}
+
+	if override.Shell != nil {
+		c.Shell = *override.Shell
+	}
+	if override.Timeout != nil {
+		c.Timeout = *override.Timeout
+	}
+	if override.Theme != nil {
+		c.Theme = *override.Theme
+	}
+	if override.Animation != nil {
+		c.Animation = *override.Animation
+	}
+	if override.FoldLines != nil {
+		c.Output.FoldLines = *override.FoldLines
+	}
+	if override.MaxKB != nil {
+		c.Output.MaxKB = *override.MaxKB
+	}
+	if override.NotifyAfter != nil {
+		c.Notify.After = *override.NotifyAfter
+	}
+	return c
+}
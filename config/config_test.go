@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file in a temporary directory
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string // Error with the path of the file removed
+	}{
+		{"empty", "", ""},
+		{"valid", `
+timeout = "5m"
+theme = "light"
+[output]
+fold_lines = 20
+[notify]
+after = "30s"
+method = "command"
+command = "notify-send \"$CAHIER_TITLE\""
+[keys]
+preset = "vim"
+bindings = { "view.new" = ["a"], "view.assert" = ["A"] }
+[notebooks.prod]
+timeout = "10s"
+fold_lines = 4
+`, ""},
+		{"syntax error", "timeout = ", ":1: unexpected EOF; expected value"},
+		{"invalid duration", `timeout = "soon"`, `:1: invalid duration "soon", expected a value such as 30s or 5m`},
+		{"unknown setting", "colour = \"red\"\n[output]\nmax = 1",
+			":\n  unknown setting \"colour\"\n  unknown setting \"output.max\""},
+		{"invalid values", "database = \"\"\ntick = \"1ms\"\n[output]\nfold_lines = 1\nmax_kb = -1",
+			":\n  database must not be empty\n  tick must be at least 10ms\n  fold_lines must be at least 2\n  max_kb must not be negative"},
+		{"negative durations", "timeout = \"-1s\"\n[notify]\nafter = \"-1s\"",
+			":\n  timeout must not be negative\n  notify.after must not be negative"},
+		{"missing shell", `shell = "cahier-missing-shell"`, ":\n  shell: \"cahier-missing-shell\" not found"},
+		{"unknown theme", `theme = "neon"`, ":\n  theme: "},
+		{"unknown notify method", "[notify]\nmethod = \"pigeon\"",
+			":\n  notify.method: unknown method \"pigeon\", expected one of bell, osc9, osc777, command"},
+		{"notify command missing", "[notify]\nmethod = \"command\"",
+			":\n  notify.command must not be empty with the command method"},
+		{"key conflict", "[keys.bindings]\n\"view.new\" = [\"d\"]",
+			":\n  keys: key \"d\" is bound to both view.delete and view.new"},
+		{"unknown preset", "[keys]\npreset = \"nano\"",
+			":\n  keys: unknown keymap preset \"nano\", expected one of default, emacs, vim"},
+		{"notebook override", "[notebooks.b]\nfold_lines = 0\n[notebooks.a]\ntimeout = \"-1s\"",
+			":\n  notebooks.a.timeout must not be negative\n  notebooks.b.fold_lines must be at least 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.content)
+			_, err := LoadFile(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadFile = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("LoadFile accepted %q", tt.content)
+			}
+			got, ok := strings.CutPrefix(err.Error(), path)
+			if !ok || !strings.HasPrefix(got, tt.wantErr) {
+				t.Errorf("LoadFile error =\n%s\nwant the path followed by\n%s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Shell != "bash" || cfg.Output.FoldLines != 10 {
+		t.Errorf("LoadFile = %+v, want the defaults", cfg)
+	}
+}
+
+func TestFor(t *testing.T) {
+	cfg, err := LoadFile(writeConfig(t, `
+timeout = "1m"
+[output]
+fold_lines = 20
+[notebooks.prod]
+timeout = "10s"
+animation = false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prod := cfg.For("prod")
+	if prod.Timeout.Duration != 10*time.Second || prod.Animation || prod.Output.FoldLines != 20 {
+		t.Errorf("For(prod) = %+v, want its overrides on top of the global settings", prod)
+	}
+	if other := cfg.For("other"); other.Timeout.Duration != time.Minute || !other.Animation {
+		t.Errorf("For(other) = %+v, want the global settings", other)
+	}
+}
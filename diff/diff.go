@@ -0,0 +1,189 @@
+package diff
+
+import (
+	"strings"
+)
+
+type Kind int
+
+const (
+	Equal Kind = iota
+	Insert
+	Delete
+)
+
+// maxEdits bounds the work done on very different inputs, past which the
+// whole remaining block is reported as replaced
+const maxEdits = 1000
+
+// Line is a line of a diff, present in both texts or only in one of them
+type Line struct {
+	Kind Kind
+	Text string
+}
+
+// Lines computes the line diff turning a into b
+func Lines(a, b string) []Line {
+	return diff(splitLines(a), splitLines(b))
+}
+
+// Changed reports whether a diff contains any insertion or deletion
+func Changed(lines []Line) bool {
+	for _, line := range lines {
+		if line.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Unified renders a diff in the unified format with the given number of
+// context lines around each change, without hunk headers
+func Unified(lines []Line, context int) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if line.Kind == Equal && !nearChange(lines, i, context) {
+			if i > 0 && nearChange(lines, i-1, context) {
+				b.WriteString("…\n")
+			}
+			continue
+		}
+		switch line.Kind {
+		case Equal:
+			b.WriteString("  ")
+		case Insert:
+			b.WriteString("+ ")
+		case Delete:
+			b.WriteString("- ")
+		}
+		b.WriteString(line.Text + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// nearChange reports whether the line at i is within context lines of a change
+func nearChange(lines []Line, i, context int) bool {
+	if context < 0 {
+		return true
+	}
+	for j := max(i-context, 0); j <= min(i+context, len(lines)-1); j++ {
+		if lines[j].Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func diff(a, b []string) []Line {
+	// Common prefix and suffix do not need the full algorithm
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		lines = append(lines, Line{Equal, text})
+	}
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Equal, text})
+	}
+	return lines
+}
+
+// myers implements the O(ND) shortest edit script algorithm by Eugene Myers
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		return replaceAll(a, b)
+	}
+
+	// Walk the trace backwards to recover the edit script
+	lines := []Line{}
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, Line{Equal, a[x]})
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, Line{Insert, b[y]})
+		} else {
+			x--
+			lines = append(lines, Line{Delete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		lines = append(lines, Line{Equal, a[x]})
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+func replaceAll(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a {
+		lines = append(lines, Line{Delete, text})
+	}
+	for _, text := range b {
+		lines = append(lines, Line{Insert, text})
+	}
+	return lines
+}
@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format writes a diff as its unified lines with every line kept
+func format(lines []Line) string {
+	return Unified(lines, -1)
+}
+
+// sides rebuilds both texts from a diff
+func sides(lines []Line) (a, b []string) {
+	for _, line := range lines {
+		if line.Kind != Insert {
+			a = append(a, line.Text)
+		}
+		if line.Kind != Delete {
+			b = append(b, line.Text)
+		}
+	}
+	return a, b
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		want    string
+		changed bool
+	}{
+		{"both empty", "", "", "", false},
+		{"equal", "a\nb\n", "a\nb", "  a\n  b", false},
+		{"from empty", "", "a\nb", "+ a\n+ b", true},
+		{"to empty", "a\nb", "", "- a\n- b", true},
+		{"insert in middle", "a\nc", "a\nb\nc", "  a\n+ b\n  c", true},
+		{"delete in middle", "a\nb\nc", "a\nc", "  a\n- b\n  c", true},
+		{"replace", "a\nb\nc", "a\nx\nc", "  a\n- b\n+ x\n  c", true},
+		{"move", "a\nb\nc", "b\nc\na", "- a\n  b\n  c\n+ a", true},
+		{"interleaved", "a\nb\nc\nd", "b\nx\nd\ny", "- a\n  b\n- c\n+ x\n  d\n+ y", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := Lines(tt.a, tt.b)
+			if got := format(lines); got != tt.want {
+				t.Errorf("Lines(%q, %q) =\n%s\nwant\n%s", tt.a, tt.b, got, tt.want)
+			}
+			if got := Changed(lines); got != tt.changed {
+				t.Errorf("Changed = %v, want %v", got, tt.changed)
+			}
+		})
+	}
+}
+
+func TestLinesPastMaxEdits(t *testing.T) {
+	var a, b []string
+	for i := range maxEdits {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append(a, "same")
+	b = append(b, "same")
+
+	lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	gotA, gotB := sides(lines)
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+		t.Fatalf("the diff does not rebuild both texts")
+	}
+	for i, line := range lines[:maxEdits] {
+		if line.Kind != Delete {
+			t.Fatalf("line %d is %v, want the changed block replaced", i, line.Kind)
+		}
+	}
+	if last := lines[len(lines)-1]; last != (Line{Equal, "same"}) {
+		t.Errorf("last line = %v, want the common suffix", last)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	lines := Lines("1\n2\n3\n4\n5\n6\n7\n8", "1\n2\n3\nx\n5\n6\n7\n8")
+	tests := []struct {
+		context int
+		want    string
+	}{
+		{-1, "  1\n  2\n  3\n- 4\n+ x\n  5\n  6\n  7\n  8"},
+		{0, "- 4\n+ x\n…"},
+		{1, "  3\n- 4\n+ x\n  5\n…"},
+		{10, "  1\n  2\n  3\n- 4\n+ x\n  5\n  6\n  7\n  8"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.context), func(t *testing.T) {
+			if got := Unified(lines, tt.context); got != tt.want {
+				t.Errorf("Unified(context %d) =\n%s\nwant\n%s", tt.context, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package diff
+
+import (
+	"strings"
+
+	"cahier/theme"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	insertStyle  lipgloss.Style
+	deleteStyle  lipgloss.Style
+	contextStyle = lipgloss.NewStyle().Faint(true)
+)
+
+// SetTheme colors the inserted and deleted lines
+func SetTheme(t theme.Theme) {
+	insertStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Success))
+	deleteStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error))
+}
+
+// Render colors a diff in the unified format, see Unified
+func Render(lines []Line, context int) string {
+	rendered := []string{}
+	for _, line := range strings.Split(Unified(lines, context), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+ "):
+			rendered = append(rendered, insertStyle.Render(line))
+		case strings.HasPrefix(line, "- "):
+			rendered = append(rendered, deleteStyle.Render(line))
+		default:
+			rendered = append(rendered, contextStyle.Render(line))
+		}
+	}
+	return strings.Join(rendered, "\n")
+}
+
+// RenderSideBySide renders the old text on the left and the new one on the
+// right, aligning unchanged lines and pairing deletions with insertions
+func RenderSideBySide(lines []Line, width int) string {
+	column := max((width-3)/2, 10)
+	cell := func(style lipgloss.Style, text string) string {
+		return style.Width(column).MaxWidth(column).Render(truncate(text, column))
+	}
+	blank := strings.Repeat(" ", column)
+
+	rows := []string{}
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			rows = append(rows, cell(contextStyle, lines[i].Text)+" │ "+cell(contextStyle, lines[i].Text))
+			i++
+			continue
+		}
+
+		// Collect the block of changes and show deletions next to insertions
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].Kind != Equal; i++ {
+			if lines[i].Kind == Delete {
+				deleted = append(deleted, lines[i].Text)
+			} else {
+				inserted = append(inserted, lines[i].Text)
+			}
+		}
+		for j := 0; j < max(len(deleted), len(inserted)); j++ {
+			left, right := blank, blank
+			if j < len(deleted) {
+				left = cell(deleteStyle, deleted[j])
+			}
+			if j < len(inserted) {
+				right = cell(insertStyle, inserted[j])
+			}
+			rows = append(rows, left+" │ "+right)
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
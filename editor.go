@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type editorFinishedMsg struct {
+	cmdID    int64
+	path     string
+	original string
+	err      error
+}
+
+// editorCommand returns the editor of the user, $VISUAL first, which may
+// come with arguments such as "code --wait"
+func editorCommand(path string) (*exec.Cmd, error) {
+	// Blank variables count as unset
+	fields := strings.Fields(os.Getenv("VISUAL"))
+	if len(fields) == 0 {
+		fields = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(fields) == 0 {
+		fields = []string{"vi"}
+	}
+
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return nil, fmt.Errorf("editor %q not found, set $EDITOR", fields[0])
+	}
+	return exec.Command(fields[0], append(fields[1:], path)...), nil
+}
+
+// openInEditor suspends the interface and opens the selected command in a
+// temporary shell script, so that the editor highlights it as such
+func openInEditor(m Model) (Model, tea.Cmd) {
+	if m.currentIdx < 0 || m.currentIdx >= len(m.cmds) {
+		return m, nil
+	}
+	cmd := m.cmds[m.currentIdx]
+
+	file, err := os.CreateTemp("", "cahier-*.sh")
+	if err != nil {
+		return showFlash(m, errorStyle.Render("Failed to create the script: "+err.Error()))
+	}
+	_, err = file.WriteString(cmd.Command + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return showFlash(m, errorStyle.Render("Failed to write the script: "+err.Error()))
+	}
+
+	editor, err := editorCommand(file.Name())
+	if err != nil {
+		os.Remove(file.Name())
+		return showFlash(m, errorStyle.Render(err.Error()))
+	}
+
+	return m, tea.ExecProcess(editor, func(err error) tea.Msg {
+		return editorFinishedMsg{cmdID: cmd.ID, path: file.Name(), original: cmd.Command, err: err}
+	})
+}
+
+// saveEditedCommand saves the script back into the command once the editor
+// exits, unless the editor failed or left it unchanged or empty
+func saveEditedCommand(m Model, msg editorFinishedMsg) (Model, tea.Cmd) {
+	defer os.Remove(msg.path)
+
+	if msg.err != nil {
+		return showFlash(m, errorStyle.Render("Editor failed, command not saved: "+msg.err.Error()))
+	}
+
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		return showFlash(m, errorStyle.Render("Failed to read the script: "+err.Error()))
+	}
+
+	command := strings.TrimRight(string(data), "\r\n")
+	switch {
+	case command == msg.original:
+		return showFlash(m, "No changes")
+	case strings.TrimSpace(command) == "":
+		return showFlash(m, "Empty command, not saved")
+	}
+
+	idx := -1
+	for i, cmd := range m.cmds {
+		if cmd.ID == msg.cmdID {
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return showFlash(m, errorStyle.Render("The cell no longer exists"))
+	}
+
+	edited := m.cmds[idx]
+	edited.Command = command
+	if err := m.store.SaveCommand(edited); err != nil {
+		return showFlash(m, errorStyle.Render("Failed to save command: "+err.Error()))
+	}
+
+	m, cmd := reloadAndSelect(m, idx)
+	if cmd != nil {
+		return m, cmd
+	}
+	return showFlash(m, fmt.Sprintf("Saved cell %d", idx+1))
+}
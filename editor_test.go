@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEditorCommand(t *testing.T) {
+	tests := []struct {
+		visual, editor string
+		want           []string
+	}{
+		{visual: "true --wait", editor: "false", want: []string{"true", "--wait", "file.sh"}},
+		{visual: "", editor: "true", want: []string{"true", "file.sh"}},
+		{visual: "  ", editor: "true -n", want: []string{"true", "-n", "file.sh"}},
+		{visual: " ", editor: "\t", want: []string{"vi", "file.sh"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("VISUAL", tt.visual)
+		t.Setenv("EDITOR", tt.editor)
+
+		cmd, err := editorCommand("file.sh")
+		if tt.want[0] == "vi" && err != nil {
+			continue // vi is not installed everywhere
+		}
+		if err != nil {
+			t.Fatalf("VISUAL=%q EDITOR=%q: %v", tt.visual, tt.editor, err)
+		}
+		if !slices.Equal(cmd.Args, tt.want) {
+			t.Errorf("VISUAL=%q EDITOR=%q: args %q, want %q", tt.visual, tt.editor, cmd.Args, tt.want)
+		}
+	}
+}
+
+func TestEditorCommandNotFound(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "no-such-editor-for-cahier")
+	if _, err := editorCommand("file.sh"); err == nil {
+		t.Error("want an error for a missing editor")
+	}
+}
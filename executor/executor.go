@@ -1,36 +1,145 @@
 package executor
 
 import (
-	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// Options change how commands run. The zero value runs them with bash,
+// without time or output limits.
+type Options struct {
+	Shell     string        // Shell running the command with -c
+	Timeout   time.Duration // Commands running longer are killed, 0 for never
+	MaxOutput int           // Bytes of output kept, 0 for no limit
+}
+
+// Chunk is a piece of output written by the command to one of its streams
+type Chunk struct {
+	Stream string
+	Time   time.Time
+	Data   string
+}
+
 type Result struct {
+	Command  string
 	Output   string
+	Chunks   []Chunk // Output split by stream, in the order it was written
 	ExitCode int
 	Error    error
+	Started  time.Time
+	Duration time.Duration
+}
+
+// recorder collects the chunks written to both streams in a single ordered list.
+// The streams are separate pipes, so writes to both within a few microseconds
+// may be recorded in either order.
+type recorder struct {
+	mu        sync.Mutex
+	chunks    []Chunk
+	size      int
+	limit     int
+	truncated bool
+}
+
+// add records a chunk, merging consecutive writes to the same stream
+func (r *recorder) add(stream, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.truncated {
+		return
+	}
+	if r.limit > 0 && r.size+len(data) > r.limit {
+		// Cut before the rune straddling the limit, so the output stays valid UTF-8
+		cut := max(r.limit-r.size, 0)
+		for cut > 0 && !utf8.RuneStart(data[cut]) {
+			cut--
+		}
+		data = data[:cut]
+		r.truncated = true
+	}
+	r.size += len(data)
+	r.append(stream, data)
+}
+
+// notice records a message of cahier on stderr, past the limit and after
+// the output was truncated
+func (r *recorder) notice(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.append(Stderr, fmt.Sprintf(format, args...))
+}
+
+// append adds data to the last chunk when it comes from the same stream
+func (r *recorder) append(stream, data string) {
+	if data == "" {
+		return
+	}
+	if n := len(r.chunks); n > 0 && r.chunks[n-1].Stream == stream {
+		r.chunks[n-1].Data += data
+	} else {
+		r.chunks = append(r.chunks, Chunk{Stream: stream, Time: time.Now(), Data: data})
+	}
 }
 
-func ExecuteCommand(command string) Result {
-	cmd := exec.Command("bash", "-c", command)
+type streamWriter struct {
+	stream   string
+	recorder *recorder
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.recorder.add(w.stream, string(p))
+	return len(p), nil
+}
+
+func ExecuteCommand(command string, opts Options) Result {
+	shell := opts.Shell
+	if shell == "" {
+		shell = "bash"
+	}
+
+	ctx, cancel := context.Background(), func() {}
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	}
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, shell, "-c", command)
+	// Children keeping the pipes open must not hold the run once it is killed
+	cmd.WaitDelay = time.Second
 
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	rec := &recorder{limit: opts.MaxOutput}
+	cmd.Stdout = streamWriter{Stdout, rec}
+	cmd.Stderr = streamWriter{Stderr, rec}
 
+	started := time.Now()
 	err := cmd.Run()
+	duration := time.Since(started)
 
-	output := stdout.String()
-	if stderr.String() != "" {
-		if output != "" {
-			output += "\n"
-		}
-		output += stderr.String()
+	// Explain why the output stops, after the limit so that it always shows
+	if rec.truncated {
+		rec.notice("\ncahier: output truncated at %d bytes\n", opts.MaxOutput)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		rec.notice("\ncahier: killed after %s\n", opts.Timeout)
 	}
 
-	output = strings.TrimRight(output, "\n")
+	var raw strings.Builder
+	for _, chunk := range rec.chunks {
+		raw.WriteString(chunk.Data)
+	}
+	output := strings.TrimRight(raw.String(), "\n")
 
 	exitCode := 0
 	if err != nil {
@@ -42,8 +151,29 @@ func ExecuteCommand(command string) Result {
 	}
 
 	return Result{
+		Command:  command,
 		Output:   output,
+		Chunks:   trimChunks(rec.chunks, len(output)),
 		ExitCode: exitCode,
 		Error:    err,
+		Started:  started,
+		Duration: duration,
+	}
+}
+
+// trimChunks cuts the chunks to the length of the trimmed output so that
+// their concatenation is always the output
+func trimChunks(chunks []Chunk, length int) []Chunk {
+	trimmed := []Chunk{}
+	for _, chunk := range chunks {
+		if length <= 0 {
+			break
+		}
+		if len(chunk.Data) > length {
+			chunk.Data = chunk.Data[:length]
+		}
+		length -= len(chunk.Data)
+		trimmed = append(trimmed, chunk)
 	}
+	return trimmed
 }
@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestExecuteCommandNotices(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		opts    Options
+		want    string
+	}{
+		{"truncated", "yes | head -c 5000", Options{MaxOutput: 100}, "cahier: output truncated at 100 bytes"},
+		{"truncated on a rune", "for i in $(seq 100); do printf é; done", Options{MaxOutput: 51}, "cahier: output truncated at 51 bytes"},
+		{"killed", "sleep 5", Options{Timeout: 200 * time.Millisecond}, "cahier: killed after 200ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ExecuteCommand(tt.command, tt.opts)
+			if !strings.HasSuffix(result.Output, tt.want) {
+				t.Errorf("output %q does not end with %q", result.Output, tt.want)
+			}
+			if !utf8.ValidString(result.Output) {
+				t.Errorf("output %q is not valid UTF-8", result.Output)
+			}
+			last := result.Chunks[len(result.Chunks)-1]
+			if last.Stream != Stderr || !strings.Contains(last.Data, tt.want) {
+				t.Errorf("last chunk %+v, want the notice on stderr", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"cahier/guard"
+	"cahier/store"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	guardPatternsSetting = "guard.patterns"
+	guardTagsSetting     = "guard.tags"
+)
+
+var (
+	confirmTitleStyle = lipgloss.NewStyle().Bold(true)
+
+	confirmBoxStyle = lipgloss.NewStyle().
+			Padding(1, 2).
+			BorderStyle(lipgloss.ThickBorder())
+)
+
+// confirmState is a guarded command waiting for a confirmation to run
+type confirmState struct {
+	notebook string
+	cmd      store.Command
+	reasons  []string
+	expanded string
+}
+
+// loadGuardRules returns the guard rules of a notebook, the default ones
+// until they are changed
+func loadGuardRules(db *store.Store) (guard.Rules, error) {
+	rules := guard.DefaultRules()
+	for key, dest := range map[string]*[]string{guardPatternsSetting: &rules.Patterns, guardTagsSetting: &rules.Tags} {
+		value, err := db.GetSetting(key)
+		if err != nil {
+			return rules, err
+		}
+		if value == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(value), dest); err != nil {
+			return rules, err
+		}
+	}
+	return rules, nil
+}
+
+func saveGuardRules(db *store.Store, rules guard.Rules) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	for key, value := range map[string][]string{guardPatternsSetting: rules.Patterns, guardTagsSetting: rules.Tags} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if err := db.SetSetting(key, string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guardReasons returns why a command of a notebook needs a confirmation, if it does
+func guardReasons(db *store.Store, cmd store.Command) []string {
+	rules, err := loadGuardRules(db)
+	if err != nil {
+		log.Printf("Failed to get guard rules: %v", err)
+		rules = guard.DefaultRules()
+	}
+	return rules.Check(cmd.Command, cmd.Tags)
+}
+
+// askConfirmation holds a guarded command until the user confirms it
+func askConfirmation(m Model, notebook string, cmd store.Command, reasons []string) Model {
+	m.confirm = &confirmState{
+		notebook: notebook,
+		cmd:      cmd,
+		reasons:  reasons,
+		expanded: guard.Expand(cmd.Command),
+	}
+	m.currentMode = ConfirmMode
+	return m
+}
+
+func HandleConfirmModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := m.keys.Confirm
+	confirm := m.confirm
+
+	switch {
+	// Run the command, recording who confirmed it
+	case key.Matches(msg, k.Yes):
+		m.confirm = nil
+		m.currentMode = ViewMode
+
+		if confirm.notebook != notebookPath(m.store) {
+			err := withNotebook(m, confirm.notebook, func(db *store.Store) error {
+				auditConfirmation(db, confirm.cmd, confirm.reasons, "confirmed in the palette")
+				return nil
+			})
+			if err != nil {
+				return showError(m, "Failed to open notebook "+confirm.notebook, err)
+			}
+			return m, runInNotebook(confirm.notebook, confirm.cmd, execOptions(m.config.For(store.NotebookName(confirm.notebook))))
+		}
+
+		auditConfirmation(m.store, confirm.cmd, confirm.reasons, "confirmed in the interface")
+		for i, cmd := range m.cmds {
+			if cmd.ID == confirm.cmd.ID {
+				return startRun(m, i)
+			}
+		}
+
+	// Leave the command as it is
+	case key.Matches(msg, k.No):
+		m.confirm = nil
+		m.currentMode = ViewMode
+		return showFlash(m, "Not run")
+	}
+
+	return m, nil
+}
+
+func (c confirmState) View(width int) string {
+	body := confirmTitleStyle.Render("⚠️  This command needs a confirmation") + "\n\n" +
+		"Matched: " + strings.Join(c.reasons, ", ") + "\n\n" +
+		"It will run as:\n\n" + c.expanded
+
+	return confirmBoxStyle.Width(max(width-4, 20)).Render(body)
+}
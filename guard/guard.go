@@ -0,0 +1,118 @@
+package guard
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Rules decide which commands need a confirmation before running
+type Rules struct {
+	Patterns []string // Regular expressions matched against the command text
+	Tags     []string // Commands carrying any of these tags always need one
+}
+
+// DefaultRules catch the usual destructive commands and the cells tagged prod
+func DefaultRules() Rules {
+	return Rules{
+		Patterns: []string{
+			`\brm\s+(-\w*[rR]\w*f|-\w*f\w*[rR]|-[rR]\s+-f|-f\s+-[rR])`,
+			`(?i)\bdrop\s+(table|database|schema)\b`,
+			`(?i)\btruncate\s+table\b`,
+			`\bkubectl\s+delete\b`,
+			`--force\b`,
+		},
+		Tags: []string{"prod"},
+	}
+}
+
+// Validate checks that every pattern compiles
+func (r Rules) Validate() error {
+	for _, pattern := range r.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Check returns why a command needs a confirmation: the patterns it
+// matches and the guarded tags it carries. Invalid patterns are skipped.
+func (r Rules) Check(command string, tags []string) []string {
+	reasons := []string{}
+	for _, pattern := range r.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err == nil && re.MatchString(command) {
+			reasons = append(reasons, re.FindString(command))
+		}
+	}
+	for _, guarded := range r.Tags {
+		for _, tag := range tags {
+			if tag == guarded {
+				reasons = append(reasons, "#"+tag)
+			}
+		}
+	}
+	return reasons
+}
+
+// Expand shows a command the way the shell would see it: variables from
+// the environment, globs and quotes are expanded in the arguments of every
+// simple command, the operators between them staying as written. Command
+// substitutions are left as they are, since running them would defeat the
+// confirmation. The command comes back unchanged when it cannot be expanded.
+func Expand(command string) string {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		return command
+	}
+
+	printer := syntax.NewPrinter()
+	cfg := &expand.Config{
+		Env:      expand.ListEnviron(os.Environ()...),
+		ReadDir2: os.ReadDir,
+		CmdSubst: func(w io.Writer, cs *syntax.CmdSubst) error {
+			return printer.Print(w, cs)
+		},
+	}
+
+	calls := []*syntax.CallExpr{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if _, ok := node.(*syntax.CmdSubst); ok {
+			return false
+		}
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+			calls = append(calls, call)
+		}
+		return true
+	})
+
+	for _, call := range calls {
+		fields, err := expand.Fields(cfg, call.Args...)
+		if err != nil || len(fields) == 0 {
+			continue
+		}
+		args := make([]*syntax.Word, len(fields))
+		for i, field := range fields {
+			// Quote the fields that would not read as a single word
+			if field == "" || strings.ContainsAny(field, " \t\n'\"\\;&|<>") {
+				if quoted, err := syntax.Quote(field, syntax.LangBash); err == nil {
+					field = quoted
+				}
+			}
+			args[i] = &syntax.Word{Parts: []syntax.WordPart{&syntax.Lit{Value: field}}}
+		}
+		call.Args = args
+	}
+
+	var b strings.Builder
+	if err := printer.Print(&b, file); err != nil {
+		return command
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,79 @@
+package guard
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		tags    []string
+		want    []string
+	}{
+		{"harmless", "ls -la", nil, []string{}},
+		{"rm -rf", "rm -rf build/", nil, []string{"rm -rf"}},
+		{"rm -fr", "rm -fr build/", nil, []string{"rm -fr"}},
+		{"rm -r -f", "rm -r -f build/", nil, []string{"rm -r -f"}},
+		{"rm -r", "rm -r build/", nil, []string{}},
+		{"drop table", "psql -c 'DROP TABLE users'", nil, []string{"DROP TABLE"}},
+		{"truncate", "psql -c 'truncate table users'", nil, []string{"truncate table"}},
+		{"kubectl delete", "kubectl delete pod web", nil, []string{"kubectl delete"}},
+		{"force", "git push --force", nil, []string{"--force"}},
+		{"force-with-lease", "git push --force-with-lease", nil, []string{"--force"}},
+		{"prod tag", "ls", []string{"db", "prod"}, []string{"#prod"}},
+		{"pattern and tag", "kubectl delete pod web", []string{"prod"}, []string{"kubectl delete", "#prod"}},
+		{"other tag", "ls", []string{"production"}, []string{}},
+	}
+	rules := DefaultRules()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.Check(tt.command, tt.tags); !slices.Equal(got, tt.want) {
+				t.Errorf("Check(%q, %q) = %q, want %q", tt.command, tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCustomRules(t *testing.T) {
+	rules := Rules{Patterns: []string{`(`, `\bdeploy\b`}, Tags: []string{"live"}}
+	if err := rules.Validate(); err == nil {
+		t.Error("Validate accepted an invalid pattern")
+	}
+	// The invalid pattern is skipped, the others still apply
+	want := []string{"deploy", "#live"}
+	if got := rules.Check("make deploy", []string{"live"}); !slices.Equal(got, want) {
+		t.Errorf("Check = %q, want %q", got, want)
+	}
+	if err := DefaultRules().Validate(); err != nil {
+		t.Errorf("the default rules are invalid: %v", err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("CAHIER_GUARD_DIR", "/srv/app data")
+	tests := []struct {
+		name    string
+		command string
+		want    string
+	}{
+		{"plain", "ls -la", "ls -la"},
+		{"quoted variable", `rm -rf "$CAHIER_GUARD_DIR/cache"`, "rm -rf '/srv/app data/cache'"},
+		{"split variable", "rm -rf $CAHIER_GUARD_DIR/cache", "rm -rf /srv/app data/cache"},
+		{"unset", `echo "$CAHIER_GUARD_UNSET"`, "echo ''"},
+		{"several commands", `cd "$CAHIER_GUARD_DIR" && ls`, "cd '/srv/app data' && ls"},
+		{"operators", `make || rm -rf "$CAHIER_GUARD_DIR"; echo done`, "make || rm -rf '/srv/app data'\necho done"},
+		{"pipeline", `cat "$CAHIER_GUARD_DIR/log" | grep -c error`, "cat '/srv/app data/log' | grep -c error"},
+		{"lines", "cd /tmp\nrm -rf build", "cd /tmp\nrm -rf build"},
+		{"command substitution", "rm -rf $(pwd)/build", "rm -rf $(pwd)/build"},
+		{"syntax error", "echo 'unclosed", "echo 'unclosed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expand(tt.command); got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	helpTitleStyle = lipgloss.NewStyle().Bold(true)
+	helpKeyStyle   = lipgloss.NewStyle()
+)
+
+// helpState holds the scrollable list of key bindings
+type helpState struct {
+	viewport viewport.Model
+}
+
+// helpSection is the bindings of one mode in the help overlay
+type helpSection struct {
+	title  string
+	keyMap help.KeyMap
+}
+
+// globalKeys lists the bindings working in every mode
+type globalKeys []key.Binding
+
+func (k globalKeys) ShortHelp() []key.Binding  { return k }
+func (k globalKeys) FullHelp() [][]key.Binding { return [][]key.Binding{k} }
+
+func openHelp(m Model) Model {
+	sections := []helpSection{
+		{"Cells", m.keys.View},
+		{"Editing a cell", m.keys.Edit},
+		{"New cell", m.keys.NewCommand},
+		{"Search, tags, filter and assertions", m.keys.Prompt},
+		{"Palette", m.keys.Palette},
+		{"Revisions", m.keys.Revisions},
+		{"Output pager", m.keys.Pager},
+		{"Confirmation", m.keys.Confirm},
+		{"Everywhere", globalKeys{m.keys.Global.Quit}},
+	}
+
+	h := help.New()
+	h.Width = m.width
+	h.ShowAll = true
+	h.Styles.FullKey = helpKeyStyle
+	h.Styles.FullDesc = lipgloss.NewStyle()
+
+	var b strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(helpTitleStyle.Render(section.title) + "\n")
+		b.WriteString(h.View(section.keyMap))
+	}
+
+	m.help.viewport = viewport.New(m.width, m.height-4)
+	m.help.viewport.SetContent(b.String())
+	m.currentMode = HelpMode
+	return m
+}
+
+func HandleHelpModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	if key.Matches(msg, m.keys.Help.Close) {
+		m.currentMode = ViewMode
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.help.viewport, cmd = m.help.viewport.Update(msg)
+	return m, cmd
+}
+
+// describeKeys lists key bindings the way the footers of every mode do
+func describeKeys(bindings ...key.Binding) string {
+	parts := []string{}
+	for _, binding := range bindings {
+		if binding.Enabled() {
+			parts = append(parts, binding.Help().Key+": "+binding.Help().Desc)
+		}
+	}
+	return strings.Join(parts, " - ")
+}
+
+// relabel describes a binding differently in a footer
+func relabel(binding key.Binding, desc string) key.Binding {
+	binding.SetHelp(binding.Help().Key, desc)
+	return binding
+}
@@ -0,0 +1,289 @@
+package highlight
+
+import (
+	"strings"
+
+	"cahier/theme"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+type Kind int
+
+const (
+	Plain Kind = iota
+	Keyword
+	String
+	Variable
+	Operator // Pipes, lists, subshells and redirections
+	Comment
+)
+
+// Token is a piece of a shell command. The tokens of a command put back
+// together give the command.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+var styles = map[Kind]lipgloss.Style{}
+
+// basicSyntax colors the tokens on terminals with 16 colors, where the closest
+// basic colors of the theme colors are often the same for every kind
+var basicSyntax = theme.Syntax{Keyword: "5", String: "2", Variable: "6", Operator: "3", Comment: "8"}
+
+// SetTheme colors the kinds of tokens. Keywords stay bold and comments italic
+// whatever the colors.
+func SetTheme(t theme.Theme) {
+	syntax := t.Syntax
+	if lipgloss.ColorProfile() == termenv.ANSI && syntax != (theme.Syntax{}) {
+		syntax = basicSyntax
+	}
+
+	styles = map[Kind]lipgloss.Style{
+		Keyword:  lipgloss.NewStyle().Foreground(lipgloss.Color(syntax.Keyword)).Bold(true),
+		String:   lipgloss.NewStyle().Foreground(lipgloss.Color(syntax.String)),
+		Variable: lipgloss.NewStyle().Foreground(lipgloss.Color(syntax.Variable)),
+		Operator: lipgloss.NewStyle().Foreground(lipgloss.Color(syntax.Operator)),
+		Comment:  lipgloss.NewStyle().Foreground(lipgloss.Color(syntax.Comment)).Italic(true),
+	}
+}
+
+var keywords = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true,
+	"for": true, "while": true, "until": true, "do": true, "done": true,
+	"case": true, "esac": true, "in": true, "select": true, "function": true,
+	"time": true, "return": true, "break": true, "continue": true, "exit": true,
+	"export": true, "local": true, "declare": true, "readonly": true, "unset": true,
+	"source": true, "alias": true, "eval": true, "exec": true,
+}
+
+// operators are tried longest first
+var operators = []string{
+	"2>&1", "<<<", "&>>", ">>", "<<", "&&", "||", ";;", ">&", "&>", "$(",
+	"|", "&", ";", "(", ")", ">", "<",
+}
+
+// Style returns the style of a kind of token
+func Style(kind Kind) lipgloss.Style {
+	return styles[kind]
+}
+
+// Bash renders a shell command with its tokens highlighted
+func Bash(src string) string {
+	var b strings.Builder
+	for _, token := range Tokenize(src) {
+		b.WriteString(Render(token.Kind, token.Text))
+	}
+	return b.String()
+}
+
+// Render styles text as a kind of token, line by line so that styles never
+// span a line break
+func Render(kind Kind, text string) string {
+	if kind == Plain || text == "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = styles[kind].Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Tokenize splits a shell command into tokens. It is a lightweight lexer
+// meant for display: anything it does not understand is plain text.
+func Tokenize(src string) []Token {
+	t := tokenizer{src: src}
+	t.run()
+	return t.tokens
+}
+
+type tokenizer struct {
+	src    string
+	pos    int
+	tokens []Token
+}
+
+// emit adds a token, merging it with the previous one when they are of the same kind
+func (t *tokenizer) emit(kind Kind, text string) {
+	if text == "" {
+		return
+	}
+	if n := len(t.tokens); n > 0 && t.tokens[n-1].Kind == kind {
+		t.tokens[n-1].Text += text
+		return
+	}
+	t.tokens = append(t.tokens, Token{kind, text})
+}
+
+// wordStart reports whether pos starts a word, where comments may begin
+func (t *tokenizer) wordStart() bool {
+	if t.pos == 0 {
+		return true
+	}
+	return strings.ContainsRune(" \t\n;|&()<>", rune(t.src[t.pos-1]))
+}
+
+func (t *tokenizer) run() {
+	for t.pos < len(t.src) {
+		c := t.src[t.pos]
+		switch {
+		case c == '#' && t.wordStart():
+			end := strings.IndexByte(t.src[t.pos:], '\n')
+			if end < 0 {
+				end = len(t.src) - t.pos
+			}
+			t.emit(Comment, t.src[t.pos:t.pos+end])
+			t.pos += end
+
+		case c == '\'':
+			end := strings.IndexByte(t.src[t.pos+1:], '\'')
+			if end < 0 {
+				end = len(t.src) - t.pos - 1
+			} else {
+				end++
+			}
+			t.emit(String, t.src[t.pos:t.pos+end+1])
+			t.pos += end + 1
+
+		case c == '"':
+			t.doubleQuoted()
+
+		case c == '\\':
+			end := min(t.pos+2, len(t.src))
+			t.emit(Plain, t.src[t.pos:end])
+			t.pos = end
+
+		case c == '$' && !strings.HasPrefix(t.src[t.pos:], "$("):
+			t.variable()
+
+		default:
+			if op := t.operator(); op != "" {
+				t.emit(Operator, op)
+				t.pos += len(op)
+			} else if isWordByte(c) {
+				t.word()
+			} else {
+				t.emit(Plain, t.src[t.pos:t.pos+1])
+				t.pos++
+			}
+		}
+	}
+}
+
+// operator returns the operator at pos, if any. A redirection may start
+// with the number of the redirected file descriptor.
+func (t *tokenizer) operator() string {
+	rest := t.src[t.pos:]
+	if len(rest) > 1 && rest[0] >= '0' && rest[0] <= '9' && (rest[1] == '>' || rest[1] == '<') && t.wordStart() {
+		if strings.HasPrefix(rest[1:], ">&") && len(rest) > 3 && rest[3] >= '0' && rest[3] <= '9' {
+			return rest[:4]
+		}
+		if strings.HasPrefix(rest[1:], ">>") {
+			return rest[:3]
+		}
+		return rest[:2]
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isWordByte(c byte) bool {
+	return !strings.ContainsRune(" \t\n;|&()<>'\"$\\#", rune(c))
+}
+
+func (t *tokenizer) word() {
+	start := t.pos
+	atStart := t.wordStart()
+	for t.pos < len(t.src) && (isWordByte(t.src[t.pos]) || t.src[t.pos] == '#') {
+		t.pos++
+	}
+	word := t.src[start:t.pos]
+
+	// Keywords only count as whole words, not as parts of "do.sh" or "x$if"
+	next := byte(' ')
+	if t.pos < len(t.src) {
+		next = t.src[t.pos]
+	}
+	if atStart && keywords[word] && strings.ContainsRune(" \t\n;|&()<>", rune(next)) {
+		t.emit(Keyword, word)
+		return
+	}
+	t.emit(Plain, word)
+}
+
+// variable reads $name, ${...} or a special parameter such as $? or $1
+func (t *tokenizer) variable() {
+	start := t.pos
+	t.pos++
+	switch {
+	case t.pos >= len(t.src):
+	case t.src[t.pos] == '{':
+		end := strings.IndexByte(t.src[t.pos:], '}')
+		if end < 0 {
+			t.pos = len(t.src)
+		} else {
+			t.pos += end + 1
+		}
+	case strings.IndexByte("@*#?$!-0123456789", t.src[t.pos]) >= 0:
+		t.pos++
+	default:
+		for t.pos < len(t.src) && isNameByte(t.src[t.pos]) {
+			t.pos++
+		}
+	}
+
+	if t.pos == start+1 {
+		t.emit(Plain, "$")
+		return
+	}
+	t.emit(Variable, t.src[start:t.pos])
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// doubleQuoted reads a double-quoted string, where variables still expand
+func (t *tokenizer) doubleQuoted() {
+	t.emit(String, `"`)
+	t.pos++
+	for t.pos < len(t.src) {
+		switch c := t.src[t.pos]; c {
+		case '"':
+			t.emit(String, `"`)
+			t.pos++
+			return
+		case '\\':
+			end := min(t.pos+2, len(t.src))
+			t.emit(String, t.src[t.pos:end])
+			t.pos = end
+		case '$':
+			if strings.HasPrefix(t.src[t.pos:], "$(") {
+				t.emit(String, "$(")
+				t.pos += 2
+				continue
+			}
+			t.variable()
+			// A lone dollar sign is part of the string
+			if n := len(t.tokens); t.tokens[n-1].Kind == Plain {
+				t.tokens[n-1].Kind = String
+				if n > 1 && t.tokens[n-2].Kind == String {
+					t.tokens[n-2].Text += t.tokens[n-1].Text
+					t.tokens = t.tokens[:n-1]
+				}
+			}
+		default:
+			t.emit(String, t.src[t.pos:t.pos+1])
+			t.pos++
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package highlight
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"cahier/theme"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []Token
+	}{
+		{"ls -la", []Token{{Plain, "ls -la"}}},
+		{"if true; then echo yes; fi", []Token{
+			{Keyword, "if"}, {Plain, " true"}, {Operator, ";"}, {Plain, " "}, {Keyword, "then"},
+			{Plain, " echo yes"}, {Operator, ";"}, {Plain, " "}, {Keyword, "fi"},
+		}},
+		{"echo 'a b' \"$HOME/x\"", []Token{
+			{Plain, "echo "}, {String, "'a b'"}, {Plain, " "}, {String, `"`}, {Variable, "$HOME"}, {String, `/x"`},
+		}},
+		{"cat f | grep x > out 2>&1", []Token{
+			{Plain, "cat f "}, {Operator, "|"}, {Plain, " grep x "}, {Operator, ">"}, {Plain, " out "}, {Operator, "2>&1"},
+		}},
+		{"echo ${name} $? # done", []Token{
+			{Plain, "echo "}, {Variable, "${name}"}, {Plain, " "}, {Variable, "$?"}, {Plain, " "}, {Comment, "# done"},
+		}},
+		{"echo a#b ./do.sh", []Token{{Plain, "echo a#b ./do.sh"}}},
+		{"echo 'unterminated", []Token{{Plain, "echo "}, {String, "'unterminated"}}},
+		{"echo \"$\"", []Token{{Plain, "echo "}, {String, `"$"`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got := Tokenize(tt.src)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) =\n%v\nwant\n%v", tt.src, got, tt.want)
+			}
+
+			// The tokens put back together give the command
+			var b strings.Builder
+			for _, token := range got {
+				b.WriteString(token.Text)
+			}
+			if b.String() != tt.src {
+				t.Errorf("tokens give %q, want %q", b.String(), tt.src)
+			}
+		})
+	}
+}
+
+func TestSetThemeColorProfile(t *testing.T) {
+	defer lipgloss.SetColorProfile(lipgloss.ColorProfile())
+
+	tests := []struct {
+		profile termenv.Profile
+		theme   string
+		want    lipgloss.TerminalColor
+	}{
+		{termenv.TrueColor, "dark", lipgloss.Color(theme.Default().Syntax.Keyword)},
+		{termenv.ANSI256, "dark", lipgloss.Color(theme.Default().Syntax.Keyword)},
+		{termenv.ANSI, "dark", lipgloss.Color(basicSyntax.Keyword)},
+		{termenv.ANSI, "monochrome", lipgloss.Color("")},
+	}
+	for _, tt := range tests {
+		lipgloss.SetColorProfile(tt.profile)
+		th, err := theme.Load(tt.theme, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetTheme(th)
+		if got := Style(Keyword).GetForeground(); got != tt.want {
+			t.Errorf("profile %v, theme %s: keyword color %v, want %v", tt.profile, tt.theme, got, tt.want)
+		}
+	}
+}
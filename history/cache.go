@@ -0,0 +1,68 @@
+package history
+
+import (
+	"strings"
+
+	"cahier/store"
+)
+
+// cellKey holds everything a rendered cell depends on. A cell is rendered
+// again only when its key changes.
+type cellKey struct {
+	index        int
+	command      string
+	status       string
+	returnCode   int
+	statusDetail string
+	tags         string
+	width        int
+	selected     bool
+	color        int // Step of the border animation, for the selected cell
+	matched      bool
+	searchTerms  string
+	runID        int64
+	runStarted   int64
+	stream       string
+	expanded     bool
+	foldLines    int
+	lint         bool
+	detail       string
+}
+
+type cachedCell struct {
+	key   cellKey
+	lines []string
+}
+
+func newCachedCell(key cellKey, text string) cachedCell {
+	return cachedCell{key: key, lines: strings.Split(text, "\n")}
+}
+
+func (m *Model) cellKey(index int, cmd store.Command) cellKey {
+	run := m.outputs[cmd.ID]
+	key := cellKey{
+		index:        index,
+		command:      cmd.Command,
+		status:       cmd.Status,
+		returnCode:   cmd.ReturnCode,
+		statusDetail: cmd.StatusDetail,
+		tags:         strings.Join(cmd.Tags, " "),
+		width:        m.terminalWidth,
+		selected:     index == m.selected,
+		matched:      m.searchMatches[cmd.ID],
+		searchTerms:  strings.Join(m.searchTerms, "\x00"),
+		runID:        run.ID,
+		runStarted:   run.StartedAt.UnixNano(),
+		stream:       m.stream,
+		expanded:     m.expanded[cmd.ID],
+		foldLines:    m.foldLines,
+		lint:         m.lint,
+	}
+	if key.selected {
+		key.color = m.colorIndex
+	}
+	if cmd.ID == m.detailID {
+		key.detail = m.detail
+	}
+	return key
+}
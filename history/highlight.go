@@ -0,0 +1,165 @@
+package history
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cahier/highlight"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var editorCursorStyle = lipgloss.NewStyle().Reverse(true)
+
+// renderCommand renders a command with its shell syntax highlighted and
+// every case-insensitive occurrence of the search terms marked
+func renderCommand(text string, terms []string) string {
+	marked := searchMarks(text, terms)
+
+	var b strings.Builder
+	offset := 0
+	for _, token := range highlight.Tokenize(text) {
+		for i := 0; i < len(token.Text); {
+			j := i
+			for j < len(token.Text) && marked[offset+j] == marked[offset+i] {
+				j++
+			}
+			if marked[offset+i] {
+				b.WriteString(searchMatchStyle.Render(token.Text[i:j]))
+			} else {
+				b.WriteString(highlight.Render(token.Kind, token.Text[i:j]))
+			}
+			i = j
+		}
+		offset += len(token.Text)
+	}
+	return b.String()
+}
+
+// searchMarks marks the bytes of text covered by any of the terms
+func searchMarks(text string, terms []string) []bool {
+	marked := make([]bool, len(text))
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		return marked
+	}
+
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if term == "" {
+			continue
+		}
+		for start := 0; ; {
+			idx := strings.Index(lower[start:], term)
+			if idx < 0 {
+				break
+			}
+			for j := start + idx; j < start+idx+len(term); j++ {
+				marked[j] = true
+			}
+			start += idx + len(term)
+		}
+	}
+	return marked
+}
+
+// highlightEditor highlights the rows drawn by the textarea, which only
+// knows plain text. The rows are read back without their styles, keeping
+// the position of the cursor, which is drawn in reverse video.
+func highlightEditor(view string) string {
+	rows := strings.Split(view, "\n")
+	plain := make([]string, len(rows))
+	cursors := make([]int, len(rows))
+	for i, row := range rows {
+		plain[i], cursors[i] = stripStyles(row)
+	}
+
+	// Tokens of the whole text, cut back into rows
+	rowTokens := make([][]highlight.Token, len(rows))
+	row := 0
+	for _, token := range highlight.Tokenize(strings.Join(plain, "\n")) {
+		for i, text := range strings.Split(token.Text, "\n") {
+			if i > 0 {
+				row++
+			}
+			if text != "" {
+				rowTokens[row] = append(rowTokens[row], highlight.Token{Kind: token.Kind, Text: text})
+			}
+		}
+	}
+
+	for i, tokens := range rowTokens {
+		var b strings.Builder
+		column := 0
+		for _, token := range tokens {
+			runes := []rune(token.Text)
+			cursor := cursors[i] - column
+			if cursor >= 0 && cursor < len(runes) {
+				b.WriteString(highlight.Render(token.Kind, string(runes[:cursor])))
+				b.WriteString(editorCursorStyle.Render(string(runes[cursor])))
+				b.WriteString(highlight.Render(token.Kind, string(runes[cursor+1:])))
+			} else {
+				b.WriteString(highlight.Render(token.Kind, token.Text))
+			}
+			column += len(runes)
+		}
+		rows[i] = b.String()
+	}
+	return strings.Join(rows, "\n")
+}
+
+// stripStyles removes the escape sequences of a row, returning its text and
+// the column of the reverse video cursor, or -1 when it is not on the row
+func stripStyles(row string) (string, int) {
+	var b strings.Builder
+	cursor, column := -1, 0
+	reverse := false
+
+	for i := 0; i < len(row); {
+		if row[i] == '\x1b' && i+1 < len(row) && row[i+1] == '[' {
+			end := i + 2
+			for end < len(row) && (row[end] < 0x40 || row[end] > 0x7e) {
+				end++
+			}
+			if end < len(row) && row[end] == 'm' {
+				reverse = sgrReverse(row[i+2:end], reverse)
+			}
+			i = end + 1
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(row[i:])
+		if reverse && cursor == -1 {
+			cursor = column
+		}
+		b.WriteRune(r)
+		column++
+		i += size
+	}
+	return b.String(), cursor
+}
+
+// sgrReverse applies the parameters of a style sequence to the reverse video state
+func sgrReverse(params string, reverse bool) bool {
+	if params == "" {
+		return false
+	}
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		switch n, _ := strconv.Atoi(fields[i]); n {
+		case 0, 27:
+			reverse = false
+		case 7:
+			reverse = true
+		case 38, 48, 58:
+			// Extended colors carry their own numbers, 5;n or 2;r;g;b
+			if i+1 < len(fields) && fields[i+1] == "5" {
+				i += 2
+			} else if i+1 < len(fields) && fields[i+1] == "2" {
+				i += 4
+			}
+		}
+	}
+	return reverse
+}
@@ -0,0 +1,61 @@
+package history
+
+import (
+	"strings"
+
+	"cahier/lint"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Issue styles, colored by SetTheme
+var (
+	lintErrorStyle   lipgloss.Style
+	lintWarningStyle lipgloss.Style
+)
+
+type lintResult struct {
+	command string
+	issues  []lint.Issue
+}
+
+// SetLint turns the checks of commands on or off
+func (m *Model) SetLint(enabled bool) {
+	m.lint = enabled
+	m.updateViewport()
+}
+
+// Linting reports whether commands are checked
+func (m *Model) Linting() bool {
+	return m.lint
+}
+
+// lintIssues checks a command, reusing the previous result while its text is unchanged
+func (m *Model) lintIssues(cmdID int64, command string) []lint.Issue {
+	if !m.lint {
+		return nil
+	}
+	if result, ok := m.lintResults[cmdID]; ok && result.command == command {
+		return result.issues
+	}
+
+	if m.lintResults == nil {
+		m.lintResults = map[int64]lintResult{}
+	}
+	issues := lint.Check(command)
+	m.lintResults[cmdID] = lintResult{command, issues}
+	return issues
+}
+
+// renderIssues renders the issues found in a command, one per line
+func renderIssues(issues []lint.Issue) string {
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		style := lintWarningStyle
+		if issue.Severity == lint.Error {
+			style = lintErrorStyle
+		}
+		lines[i] = style.Render(issue.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,54 @@
+package history
+
+import (
+	"github.com/charmbracelet/lipgloss"
+)
+
+// textColumn is the column where the text of a cell starts, after its number,
+// border and padding. Selected cells have a narrower number and a wider
+// padding, so it is the same for every cell.
+const textColumn = 8
+
+// CellAt returns the index of the command shown at column x and row y of the
+// history, or -1 when there is none, and whether x, y is on its status icon
+func (m *Model) CellAt(x, y int) (int, bool) {
+	if !m.ready || y < 0 || y >= m.viewport.Height || x >= m.terminalWidth {
+		return -1, false
+	}
+	line := m.offset + y
+
+	for i, cmd := range m.commands {
+		start := m.linePositions[i]
+		if line < start || line >= start+m.cellHeights[i] {
+			continue
+		}
+
+		// The status comes first on the line below the top border and padding
+		icon := statusIcon(cmd)
+		onStatus := i != m.editingIndex && icon != "" && line == start+2 &&
+			x >= textColumn && x < textColumn+lipgloss.Width(icon)
+		return i, onStatus
+	}
+	return -1, false
+}
+
+// SelectInPlace selects the command at index, scrolling only as much as needed
+// to show it whole, so that it stays under a pointer that clicked it
+func (m *Model) SelectInPlace(index int) {
+	if index < 0 || index >= len(m.commands) {
+		return
+	}
+	m.selected = index
+	m.updateViewport()
+	if !m.ready || index >= len(m.linePositions) {
+		return
+	}
+
+	start, height := m.linePositions[index], m.cellHeights[index]
+	switch {
+	case start < m.offset:
+		m.scrollTo(start)
+	case start+height > m.offset+m.viewport.Height:
+		m.scrollTo(min(start, start+height-m.viewport.Height))
+	}
+}
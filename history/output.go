@@ -0,0 +1,179 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	"cahier/store"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultFoldLines is how many output lines a collapsed cell shows
+const DefaultFoldLines = 10
+
+// Output styles, colored by SetTheme
+var (
+	outputStyle     lipgloss.Style
+	stderrStyle     lipgloss.Style
+	outputRuleStyle lipgloss.Style
+)
+
+// SetOutputs sets the last run of every command, by command ID
+func (m *Model) SetOutputs(runs map[int64]store.Run) {
+	m.outputs = runs
+	m.updateViewport()
+}
+
+// SetOutput sets the last run of a command
+func (m *Model) SetOutput(run store.Run) {
+	if m.outputs == nil {
+		m.outputs = map[int64]store.Run{}
+	}
+	m.outputs[run.CommandID] = run
+	m.updateViewport()
+}
+
+// Output returns the output of the last run of the command at index, limited
+// to the shown stream
+func (m *Model) Output(index int) (string, bool) {
+	if index < 0 || index >= len(m.commands) {
+		return "", false
+	}
+	run, ok := m.outputs[m.commands[index].ID]
+	return run.Stream(m.stream), ok
+}
+
+// LastRun returns the last run of the command at index, if it ran
+func (m *Model) LastRun(index int) (store.Run, bool) {
+	if index < 0 || index >= len(m.commands) {
+		return store.Run{}, false
+	}
+	run, ok := m.outputs[m.commands[index].ID]
+	return run, ok
+}
+
+// StderrLines tells which lines of Output were written, at least partly, to stderr
+func (m *Model) StderrLines(index int) []bool {
+	if index < 0 || index >= len(m.commands) {
+		return nil
+	}
+	lines := m.outputLines(m.commands[index].ID)
+	stderr := make([]bool, len(lines))
+	for i, line := range lines {
+		for _, piece := range line {
+			stderr[i] = stderr[i] || piece.Stream == store.StreamStderr
+		}
+	}
+	return stderr
+}
+
+// CycleStream switches the shown outputs from both streams to stdout only,
+// then stderr only
+func (m *Model) CycleStream() {
+	switch m.stream {
+	case "":
+		m.stream = store.StreamStdout
+	case store.StreamStdout:
+		m.stream = store.StreamStderr
+	default:
+		m.stream = ""
+	}
+	m.updateViewport()
+	m.ensureSelectedVisible()
+}
+
+// Stream returns the only stream shown, or an empty string when both are
+func (m *Model) Stream() string {
+	return m.stream
+}
+
+// ToggleExpanded switches the output of the command at index between the
+// head/tail summary and the full output
+func (m *Model) ToggleExpanded(index int) {
+	if index < 0 || index >= len(m.commands) {
+		return
+	}
+	if m.expanded == nil {
+		m.expanded = map[int64]bool{}
+	}
+	id := m.commands[index].ID
+	m.expanded[id] = !m.expanded[id]
+	m.updateViewport()
+	m.ensureSelectedVisible()
+}
+
+// SetFoldLines sets how many output lines collapsed cells show
+func (m *Model) SetFoldLines(lines int) {
+	m.foldLines = max(lines, 2)
+	m.updateViewport()
+}
+
+// outputLines splits the last output of a command into lines made of the
+// pieces of the chunks they come from, keeping only the shown stream
+func (m *Model) outputLines(cmdID int64) [][]store.Chunk {
+	run, ok := m.outputs[cmdID]
+	if !ok {
+		return nil
+	}
+
+	lines := [][]store.Chunk{nil}
+	for _, chunk := range run.Chunks {
+		if m.stream != "" && chunk.Stream != m.stream {
+			continue
+		}
+		for i, text := range strings.Split(chunk.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if text != "" {
+				piece := chunk
+				piece.Text = text
+				lines[len(lines)-1] = append(lines[len(lines)-1], piece)
+			}
+		}
+	}
+
+	// A stream on its own may end with the newlines trimmed from the output
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// renderOutput renders the output of a command below its text, folded to
+// its first and last lines unless the cell is expanded. Text written to
+// stderr stands out from stdout.
+func (m *Model) renderOutput(cmdID int64, width int) string {
+	outputLines := m.outputLines(cmdID)
+	if len(outputLines) == 0 {
+		return ""
+	}
+
+	lines := make([]string, len(outputLines))
+	for i, line := range outputLines {
+		for _, piece := range line {
+			if piece.Stream == store.StreamStderr {
+				lines[i] += stderrStyle.Render(piece.Text)
+			} else {
+				lines[i] += outputStyle.Render(piece.Text)
+			}
+		}
+	}
+
+	if !m.expanded[cmdID] && len(lines) > m.foldLines {
+		head := (m.foldLines + 1) / 2
+		tail := m.foldLines - head
+		hidden := len(lines) - head - tail
+		summary := outputRuleStyle.Render(fmt.Sprintf("… %d lines hidden, %s to expand, %s to page …",
+			hidden, keyName(m.keys.Fold), keyName(m.keys.Pager)))
+		lines = append(append(lines[:head:head], summary), lines[len(lines)-tail:]...)
+	}
+
+	rule := strings.Repeat("─", max(width, 1))
+	if m.stream != "" {
+		label := "── " + m.stream + " only "
+		rule = label + strings.Repeat("─", max(width-lipgloss.Width(label), 1))
+	}
+	return outputRuleStyle.Render(rule) + "\n" + strings.Join(lines, "\n")
+}
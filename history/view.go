@@ -1,9 +1,12 @@
 package history
 
 import (
+	"cahier/keymap"
 	"cahier/store"
 	ta "cahier/textarea"
+	"cahier/theme"
 	"fmt"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,34 +16,14 @@ import (
 )
 
 var (
-	// Rainbow colors for animated border (pastel rainbow with smooth transitions)
-	rainbowColors = []string{
-		"#FFB3BA", // Pastel pink
-		"#FFC7B3", // Pink-orange transition
-		"#FFDAB3", // Pastel orange
-		"#FFEDB3", // Orange-yellow transition
-		"#FFFFB3", // Pastel yellow
-		"#D7FFB3", // Yellow-green transition
-		"#BAFFB3", // Pastel green
-		"#B3FFD7", // Green-cyan transition
-		"#B3FFFF", // Pastel cyan
-		"#B3E5FF", // Cyan-blue transition
-		"#B3CCFF", // Light blue
-		"#B3BAFF", // Pastel blue
-		"#C7B3FF", // Blue-purple transition 1
-		"#D3B3FF", // Blue-purple transition 2
-		"#E0B3FF", // Pastel purple
-		"#EDB3FF", // Purple-magenta transition
-		"#FFB3F0", // Pastel magenta
-		"#FFB3D7", // Magenta-pink transition
-	}
+	// Theme coloring the cells, see SetTheme
+	currentTheme theme.Theme
 
 	// Cell container styles
 	normalCellStyle = lipgloss.NewStyle().
 			Padding(1, 1).
 			Margin(0, 0, 0, 0).
-			BorderStyle(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#E8E8E8")) // Light gray border
+			BorderStyle(lipgloss.RoundedBorder())
 
 	// Base style for selected cell (border color will be dynamic)
 	selectedCellBaseStyle = lipgloss.NewStyle().
@@ -51,7 +34,6 @@ var (
 
 	// Cell number styles
 	cellNumberStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#B19CD9")). // Muted purple
 			Width(5).
 			Align(lipgloss.Right).
 			MarginRight(1)
@@ -66,13 +48,51 @@ var (
 	// Content container (width will be set dynamically)
 	cellContentStyle = lipgloss.NewStyle()
 
+	// Highlight for search terms found in a command
+	searchMatchStyle lipgloss.Style
+
+	// Cell number of commands matching the search
+	matchedCellNumberStyle lipgloss.Style
+
+	// Explanation of the status, such as failed assertions
+	statusDetailStyle lipgloss.Style
+
+	// Status shown before the command
+	statusStyles map[string]lipgloss.Style
+
+	// Tag chips shown under the command
+	tagChipStyle lipgloss.Style
+
 	// Empty state style
 	emptyStateStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#B19CD9")).
 			Italic(true).
 			Padding(2, 4)
 )
 
+// SetTheme colors the cells, their outputs and their checks
+func SetTheme(t theme.Theme) {
+	currentTheme = t
+
+	normalCellStyle = normalCellStyle.BorderForeground(lipgloss.Color(t.Border))
+	cellNumberStyle = cellNumberStyle.Foreground(lipgloss.Color(t.Accent))
+	searchMatchStyle = t.Marked(t.Highlight)
+	matchedCellNumberStyle = cellNumberStyle.Foreground(lipgloss.Color(t.Warning))
+	statusDetailStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error))
+	statusStyles = map[string]lipgloss.Style{
+		store.StatusSuccess:         lipgloss.NewStyle().Foreground(lipgloss.Color(t.Success)),
+		store.StatusFailed:          lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error)),
+		store.StatusAssertionFailed: lipgloss.NewStyle().Foreground(lipgloss.Color(t.Warning)),
+	}
+	tagChipStyle = t.Marked(t.Accent).Padding(0, 1)
+	emptyStateStyle = emptyStateStyle.Foreground(lipgloss.Color(t.Accent))
+
+	outputStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Output))
+	stderrStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error))
+	outputRuleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Muted))
+	lintErrorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error))
+	lintWarningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Warning))
+}
+
 type Model struct {
 	commands      []store.Command
 	selected      int
@@ -84,6 +104,22 @@ type Model struct {
 	viewport      viewport.Model
 	ready         bool
 	linePositions []int // Track the starting line position of each command
+	searchTerms   []string
+	searchMatches map[int64]bool // IDs of the commands matching the search
+	filter        store.Filter   // Commands not matching the filter are hidden
+	detailID      int64          // ID of the command showing the detail below its text
+	detail        string
+	outputs       map[int64]store.Run // Last run of each command
+	stream        string              // Only stream shown in outputs, or empty for both
+	expanded      map[int64]bool      // Commands showing their full output
+	foldLines     int                 // Output lines shown by collapsed cells
+	keys          keymap.View         // Bindings named in the hints
+	lint          bool                // Whether issues found in commands are shown
+	lintResults   map[int64]lintResult
+	cells         map[int64]cachedCell // Rendered cells by command ID
+	cellHeights   []int                // Lines taken by each command, 0 when hidden
+	lines         []string             // Lines of every visible cell, of which the viewport shows a window
+	offset        int                  // First line shown
 }
 
 func NewModel(commands []store.Command) Model {
@@ -103,108 +139,237 @@ func NewModel(commands []store.Command) Model {
 		viewport:      vp,
 		ready:         false,
 		linePositions: make([]int, 0),
+		foldLines:     DefaultFoldLines,
+		keys:          keymap.Default().View,
 	}
 }
 
+// SetKeys sets the bindings named in the hints, such as the one to create a cell
+func (m *Model) SetKeys(keys keymap.View) {
+	m.keys = keys
+	m.Refresh()
+}
+
+// keyName shows the first key of a binding, the way the hints name it
+func keyName(binding key.Binding) string {
+	if len(binding.Keys()) == 0 {
+		return ""
+	}
+	if k := binding.Keys()[0]; k != " " {
+		return k
+	}
+	return "space"
+}
+
 func (m *Model) UpdateAnimation() {
 	// Update color index for rainbow animation (cycle every 100ms)
-	if time.Since(m.lastUpdate) > 200*time.Millisecond {
-		m.colorIndex = (m.colorIndex + 1) % len(rainbowColors)
+	if time.Since(m.lastUpdate) > 200*time.Millisecond && currentTheme.Animated() {
+		m.colorIndex = (m.colorIndex + 1) % len(currentTheme.Selected)
 		m.lastUpdate = time.Now()
+
+		// Only the selected cell is rendered again
+		if m.selected >= 0 {
+			m.updateViewport()
+		}
 	}
 }
 
 func (m Model) View() string {
 	if len(m.commands) == 0 {
-		return emptyStateStyle.Render("📝 No commands yet. Press 'n' to create one.")
+		return emptyStateStyle.Render("📝 No commands yet. Press '" + keyName(m.keys.New) + "' to create one.")
 	}
 
 	if !m.ready {
 		return "Initializing..."
 	}
 
+	if !m.hasVisible() {
+		return emptyStateStyle.Render("🔍 No commands match '" + m.filter.String() + "'. Press " + keyName(m.keys.Clear) + " to clear the filter.")
+	}
+
 	return m.viewport.View()
 }
 
-func (m *Model) renderContent() string {
-	var cells []string
+// renderContent renders the cells that changed since the last time and lays
+// out the lines of every visible cell
+func (m *Model) renderContent() {
+	m.lines = m.lines[:0]
 	m.linePositions = make([]int, len(m.commands))
+	m.cellHeights = make([]int, len(m.commands))
+	cache := make(map[int64]cachedCell, len(m.commands))
 	currentLine := 0
 
 	for i, cmd := range m.commands {
 		// Store the starting line position for this command
 		m.linePositions[i] = currentLine
 
-		// Create cell number without brackets
-		cellNum := fmt.Sprintf("%d:", i+1)
-
-		// Choose styles based on selection
-		var cellStyle, numberStyle lipgloss.Style
-		var currentContentWidth int
-		if i == m.selected {
-			// Create animated rainbow border and number for selected cell
-			rainbowColor := rainbowColors[m.colorIndex]
-			cellStyle = selectedCellBaseStyle.BorderForeground(lipgloss.Color(rainbowColor))
-			numberStyle = selectedCellNumberBaseStyle.Foreground(lipgloss.Color(rainbowColor))
-			// Selected cell has more padding
-			currentContentWidth = m.terminalWidth - 4 - 1 - 6 - 2
+		// Hidden commands take no space
+		if !m.filter.Matches(cmd) {
+			continue
+		}
+
+		// Only the cells that changed since the last render are rendered again.
+		// The cell being edited changes with every keystroke so it is not cached.
+		var cell cachedCell
+		if i == m.editingIndex {
+			cell = newCachedCell(cellKey{}, m.renderCell(i, cmd))
 		} else {
-			cellStyle = normalCellStyle
-			numberStyle = cellNumberStyle
-			currentContentWidth = m.terminalWidth - 4 - 1 - 4 - 2
+			key := m.cellKey(i, cmd)
+			cached, ok := m.cells[cmd.ID]
+			if !ok || cached.key != key {
+				cached = newCachedCell(key, m.renderCell(i, cmd))
+			}
+			cache[cmd.ID] = cached
+			cell = cached
 		}
 
-		if currentContentWidth < 20 {
-			currentContentWidth = 20 // Minimum width
+		// Cells all have the terminal width, so they stack without padding
+		m.lines = append(m.lines, cell.lines...)
+		m.cellHeights[i] = len(cell.lines)
+		currentLine += len(cell.lines)
+	}
+	m.cells = cache
+}
+
+// renderCell renders the command at index with its number, status, output and tags
+func (m *Model) renderCell(i int, cmd store.Command) string {
+	// Create cell number without brackets
+	cellNum := fmt.Sprintf("%d:", i+1)
+
+	// Choose styles based on selection
+	var cellStyle, numberStyle lipgloss.Style
+	var currentContentWidth int
+	if i == m.selected {
+		// Create animated rainbow border and number for selected cell
+		rainbowColor := currentTheme.SelectedColor(m.colorIndex)
+		cellStyle = selectedCellBaseStyle.BorderForeground(rainbowColor)
+		numberStyle = selectedCellNumberBaseStyle.Foreground(rainbowColor)
+		// Selected cell has more padding
+		currentContentWidth = m.terminalWidth - 4 - 1 - 6 - 2
+	} else {
+		cellStyle = normalCellStyle
+		numberStyle = cellNumberStyle
+		if m.searchMatches[cmd.ID] {
+			numberStyle = matchedCellNumberStyle
 		}
+		currentContentWidth = m.terminalWidth - 4 - 1 - 4 - 2
+	}
 
-		// Render cell number and content with dynamic width
-		cellNumber := numberStyle.Render(cellNum)
+	if currentContentWidth < 20 {
+		currentContentWidth = 20 // Minimum width
+	}
 
-		var cellContent string
-		var cell string
+	// Render cell number and content with dynamic width
+	cellNumber := numberStyle.Render(cellNum)
 
-		// Check if this command is being edited inline
-		if i == m.editingIndex {
-			// Render the textarea for inline editing
-			m.textarea.SetWidth(currentContentWidth)
-			cellContent = m.textarea.View()
-			cell = lipgloss.JoinHorizontal(
-				lipgloss.Center,
-				cellNumber,
-				cellStyle.Render(cellContent),
-			)
-		} else {
-			// Render normal command text with status indicator
-			statusIcon := ""
-			switch cmd.Status {
-			case store.StatusRunning:
-				statusIcon = "🔄 "
-			case store.StatusSuccess:
-				statusIcon = "✅ "
-			case store.StatusFailed:
-				statusIcon = fmt.Sprintf("❌ (exit %d) ", cmd.ReturnCode)
-			}
-			
-			commandText := statusIcon + cmd.Command
-			cellContent = cellContentStyle.Width(currentContentWidth).Render(commandText)
-			cell = lipgloss.JoinHorizontal(
-				lipgloss.Center,
-				cellNumber,
-				cellStyle.Render(cellContent),
-			)
+	var cellContent string
+	var cell string
+
+	// Check if this command is being edited inline
+	if i == m.editingIndex {
+		// Render the textarea for inline editing
+		m.textarea.SetWidth(currentContentWidth)
+		cellContent = highlightEditor(m.textarea.View())
+		if issues := m.lintIssues(-1, m.textarea.Value()); len(issues) > 0 {
+			cellContent += "\n\n" + renderIssues(issues)
+		}
+		cell = lipgloss.JoinHorizontal(
+			lipgloss.Center,
+			cellNumber,
+			cellStyle.Render(cellContent),
+		)
+	} else {
+		// Render normal command text with status indicator
+		commandText := statusStyles[cmd.Status].Render(statusIcon(cmd)) + renderCommand(cmd.Command, m.searchTerms)
+		if issues := m.lintIssues(cmd.ID, cmd.Command); len(issues) > 0 {
+			commandText += "\n\n" + renderIssues(issues)
+		}
+		if cmd.StatusDetail != "" {
+			commandText += "\n\n" + statusDetailStyle.Render(cmd.StatusDetail)
+		}
+		if output := m.renderOutput(cmd.ID, currentContentWidth); output != "" {
+			commandText += "\n" + output
 		}
+		if len(cmd.Tags) > 0 {
+			commandText += "\n\n" + renderTags(cmd.Tags)
+		}
+		if cmd.ID == m.detailID && m.detail != "" {
+			commandText += "\n\n" + m.detail
+		}
+		cellContent = cellContentStyle.Width(currentContentWidth).Render(commandText)
+		cell = lipgloss.JoinHorizontal(
+			lipgloss.Center,
+			cellNumber,
+			cellStyle.Render(cellContent),
+		)
+	}
+
+	return cell
+}
 
-		cells = append(cells, cell)
+// statusIcon shows the status of a command before its text
+func statusIcon(cmd store.Command) string {
+	switch cmd.Status {
+	case store.StatusRunning:
+		return "🔄 "
+	case store.StatusSuccess:
+		return "✅ "
+	case store.StatusFailed:
+		return fmt.Sprintf("❌ (exit %d) ", cmd.ReturnCode)
+	case store.StatusAssertionFailed:
+		return "⚠️ (assertion failed) "
+	}
+	return ""
+}
 
-		// Calculate how many lines this cell takes up
-		// Count newlines in the rendered cell plus 1 for the cell itself
-		cellLines := strings.Count(cell, "\n") + 1
-		currentLine += cellLines
+// renderTags renders the tags of a command as chips
+func renderTags(tags []string) string {
+	chips := make([]string, len(tags))
+	for i, tag := range tags {
+		chips[i] = tagChipStyle.Render("#" + tag)
 	}
+	return strings.Join(chips, " ")
+}
 
-	// Join all cells vertically
-	return lipgloss.JoinVertical(lipgloss.Left, cells...)
+// SetSearch highlights the terms in every command and marks the matching ones
+func (m *Model) SetSearch(terms []string, matches map[int64]bool) {
+	m.searchTerms = terms
+	m.searchMatches = matches
+	m.updateViewport()
+}
+
+// SetDetail shows content below the text of a command, such as the diff of
+// its output. A zero ID hides it.
+func (m *Model) SetDetail(cmdID int64, detail string) {
+	m.detailID = cmdID
+	m.detail = detail
+	m.updateViewport()
+	m.ensureSelectedVisible()
+}
+
+// SetFilter hides the commands not matching the filter
+func (m *Model) SetFilter(filter store.Filter) {
+	m.filter = filter
+	m.updateViewport()
+	m.ensureSelectedVisible()
+}
+
+func (m *Model) Filter() store.Filter {
+	return m.filter
+}
+
+// IsVisible reports whether the command at index matches the filter
+func (m *Model) IsVisible(index int) bool {
+	return index >= 0 && index < len(m.commands) && m.filter.Matches(m.commands[index])
+}
+
+func (m *Model) hasVisible() bool {
+	for i := range m.commands {
+		if m.IsVisible(i) {
+			return true
+		}
+	}
+	return false
 }
 
 func (m *Model) Select(index int) {
@@ -229,42 +394,56 @@ func (m *Model) ensureSelectedVisible() {
 	selectedLine := m.linePositions[m.selected]
 
 	// Calculate the height of the selected command
-	// We need to find the start of the next command or use total line count for the last command
-	var selectedHeight int
-	if m.selected < len(m.linePositions)-1 {
-		selectedHeight = m.linePositions[m.selected+1] - selectedLine
-	} else {
-		// For the last command, estimate based on average or use a reasonable default
-		selectedHeight = 4 // Default estimate for last command
-	}
+	selectedHeight := m.cellHeights[m.selected]
 
 	// Calculate the desired offset to center the selected item
 	// We want the middle of the selected item to appear in the middle of the viewport
 	viewportMiddle := m.viewport.Height / 2
 	selectedMiddle := selectedLine + (selectedHeight / 2)
 
-	// Ensure we don't scroll past the top
-	desiredOffset := max(selectedMiddle-viewportMiddle, 0)
-	// Ensure we don't scroll past the bottom
-	maxOffset := max(m.viewport.TotalLineCount()-m.viewport.Height, 0)
-	if desiredOffset > maxOffset {
-		desiredOffset = maxOffset
-	}
-
-	// Set the viewport offset to center the selected item
-	m.viewport.SetYOffset(desiredOffset)
+	// Set the offset to center the selected item, scrollTo keeps it in bounds
+	m.scrollTo(selectedMiddle - viewportMiddle)
 }
 
 func (m *Model) ScrollToBottom() {
 	if !m.ready {
 		return
 	}
-	m.viewport.GotoBottom()
+	m.scrollTo(m.maxOffset())
 }
 
 func (m *Model) updateViewport() {
-	content := m.renderContent()
-	m.viewport.SetContent(content)
+	m.renderContent()
+	m.showLines()
+}
+
+// showLines hands the visible lines only to the viewport, which measures
+// every line it is given
+func (m *Model) showLines() {
+	m.offset = min(max(m.offset, 0), m.maxOffset())
+	end := min(m.offset+m.viewport.Height, len(m.lines))
+	m.viewport.SetContent(strings.Join(m.lines[m.offset:end], "\n"))
+}
+
+// scrollTo shows the lines from offset, staying within the content
+func (m *Model) scrollTo(offset int) {
+	m.offset = offset
+	m.showLines()
+}
+
+// Scroll moves the shown lines by some lines, up when negative
+func (m *Model) Scroll(lines int) {
+	m.scrollTo(m.offset + lines)
+}
+
+func (m *Model) maxOffset() int {
+	return max(len(m.lines)-m.viewport.Height, 0)
+}
+
+// Refresh renders every cell again, such as after a theme change
+func (m *Model) Refresh() {
+	m.cells = nil
+	m.updateViewport()
 }
 
 func (m *Model) SetCommands(commands []store.Command) {
@@ -285,9 +464,9 @@ func (m *Model) SetWidth(width int) {
 func (m *Model) SetHeight(height int, isEditMode bool) {
 	// Calculate available height:
 	// - App header: 3 lines (title + 2 newlines)
-	// - Footer: 1 line
+	// - Status bar and footer: 2 lines
 	// - Bottom margin: 2 lines
-	reservedLines := 6
+	reservedLines := 7
 
 	// Reserve additional space when in edit mode for the textarea
 	if isEditMode {
@@ -313,6 +492,11 @@ func (m *Model) SetHeight(height int, isEditMode bool) {
 	}
 }
 
+// Height returns the number of lines the cells are shown on
+func (m *Model) Height() int {
+	return m.viewport.Height
+}
+
 func (m *Model) StartInlineEdit(index int) {
 	if index >= 0 && index < len(m.commands) {
 		m.editingIndex = index
@@ -345,24 +529,33 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
 
-	// If editing inline, pass keyboard input to textarea first
-	if m.editingIndex >= 0 {
-		switch msg := msg.(type) {
-		case tea.KeyMsg:
-			// Let parent handle special keys like ctrl+r and esc
-			key := msg.String()
-			if key != "ctrl+r" && key != "esc" {
-				m.textarea, cmd = m.textarea.Update(msg)
-				cmds = append(cmds, cmd)
-				m.updateViewport()
-				// Don't let viewport process keyboard events during inline editing
-				return m, tea.Batch(cmds...)
-			}
-		}
+	// If editing inline, pass keyboard input to textarea first. The parent
+	// handles the keys running, saving or cancelling the edit.
+	if _, ok := msg.(tea.KeyMsg); ok && m.editingIndex >= 0 {
+		m.textarea, cmd = m.textarea.Update(msg)
+		cmds = append(cmds, cmd)
+		m.updateViewport()
+		// Don't let viewport process keyboard events during inline editing
+		return m, tea.Batch(cmds...)
 	}
 
-	// Only update viewport if not processing inline edit keyboard events
-	m.viewport, cmd = m.viewport.Update(msg)
-	cmds = append(cmds, cmd)
+	// Scroll with the keys of the viewport, which only holds the visible lines
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		k := m.viewport.KeyMap
+		switch {
+		case key.Matches(msg, k.Up):
+			m.scrollTo(m.offset - 1)
+		case key.Matches(msg, k.Down):
+			m.scrollTo(m.offset + 1)
+		case key.Matches(msg, k.PageUp):
+			m.scrollTo(m.offset - m.viewport.Height)
+		case key.Matches(msg, k.PageDown):
+			m.scrollTo(m.offset + m.viewport.Height)
+		case key.Matches(msg, k.HalfPageUp):
+			m.scrollTo(m.offset - m.viewport.Height/2)
+		case key.Matches(msg, k.HalfPageDown):
+			m.scrollTo(m.offset + m.viewport.Height/2)
+		}
+	}
 	return m, tea.Batch(cmds...)
 }
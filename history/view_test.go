@@ -0,0 +1,177 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"cahier/keymap"
+	"cahier/store"
+	"cahier/theme"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// newTestModel returns a history of n cells with outputs of a few dozen
+// lines, tags on some and a failure on others
+func newTestModel(n int) Model {
+	SetTheme(theme.Default())
+
+	commands := make([]store.Command, n)
+	outputs := map[int64]store.Run{}
+	for i := range commands {
+		id := int64(i + 1)
+		commands[i] = store.Command{ID: id, Command: fmt.Sprintf("echo cell %d | grep -c cell", i+1), Status: store.StatusSuccess}
+		if i%5 == 0 {
+			commands[i].Tags = []string{"slow", "ci"}
+		}
+		if i%7 == 0 {
+			commands[i].Status = store.StatusFailed
+			commands[i].ReturnCode = 1
+			commands[i].StatusDetail = "expected exit 0"
+		}
+
+		output := strings.Repeat(fmt.Sprintf("line of output of cell %d\n", i+1), 3+i%40)
+		started := time.Unix(0, id)
+		outputs[id] = store.Run{
+			ID: id, CommandID: id, StartedAt: started, Output: output,
+			Chunks: []store.Chunk{{Stream: store.StreamStdout, Time: started, Text: output}},
+		}
+	}
+
+	m := NewModel(commands)
+	m.SetOutputs(outputs)
+	m.SetWidth(120)
+	m.SetHeight(40, false)
+	m.Select(n / 2)
+	return m
+}
+
+func TestCellHeights(t *testing.T) {
+	m := newTestModel(40)
+	m.ToggleExpanded(3)
+	m.SetDetail(m.commands[5].ID, "detail\non two lines")
+	m.SetFilter(store.Filter{Tags: []string{"ci"}})
+	m.SetFilter(store.Filter{})
+	m.commands[9].Tags = []string{"hidden"}
+	m.SetFilter(store.Filter{Tags: []string{"slow"}})
+	m.SetFilter(store.Filter{})
+
+	check := func(t *testing.T) {
+		t.Helper()
+		line := 0
+		for i, cmd := range m.commands {
+			if m.linePositions[i] != line {
+				t.Errorf("cell %d starts at line %d, want %d", i+1, m.linePositions[i], line)
+			}
+			want := 0
+			if m.filter.Matches(cmd) {
+				want = lipgloss.Height(m.renderCell(i, cmd))
+			}
+			if m.cellHeights[i] != want {
+				t.Errorf("cell %d is %d lines high, want %d", i+1, m.cellHeights[i], want)
+			}
+			line += want
+		}
+		if len(m.lines) != line {
+			t.Errorf("%d lines shown, want %d", len(m.lines), line)
+		}
+	}
+
+	t.Run("every cell", check)
+
+	m.SetFilter(store.Filter{Tags: []string{"ci"}})
+	t.Run("filtered", check)
+}
+
+// benchmarkCells is how many cells the benchmarks show
+const benchmarkCells = 300
+
+func BenchmarkUpdateViewport(b *testing.B) {
+	b.Run("warm", func(b *testing.B) {
+		m := newTestModel(benchmarkCells)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.updateViewport()
+		}
+	})
+	b.Run("cold", func(b *testing.B) {
+		m := newTestModel(benchmarkCells)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.cells = nil
+			m.updateViewport()
+		}
+	})
+}
+
+func BenchmarkUpdateAnimation(b *testing.B) {
+	b.Run("warm", func(b *testing.B) {
+		m := newTestModel(benchmarkCells)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.lastUpdate = time.Time{}
+			m.UpdateAnimation()
+		}
+	})
+	b.Run("cold", func(b *testing.B) {
+		m := newTestModel(benchmarkCells)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.cells = nil
+			m.lastUpdate = time.Time{}
+			m.UpdateAnimation()
+		}
+	})
+}
+
+func BenchmarkSelect(b *testing.B) {
+	b.Run("warm", func(b *testing.B) {
+		m := newTestModel(benchmarkCells)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Select(i % benchmarkCells)
+		}
+	})
+	b.Run("cold", func(b *testing.B) {
+		m := newTestModel(benchmarkCells)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.cells = nil
+			m.Select(i % benchmarkCells)
+		}
+	})
+}
+
+func TestHintsFollowKeys(t *testing.T) {
+	tests := []struct {
+		preset    string
+		wantEmpty string
+		wantFold  string
+	}{
+		{"default", "Press 'n' to create one", "o to expand, p to page"},
+		{"vim", "Press 'o' to create one", "space to expand, p to page"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset, func(t *testing.T) {
+			keys, err := keymap.New(tt.preset, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			empty := NewModel(nil)
+			empty.SetKeys(keys.View)
+			if got := empty.View(); !strings.Contains(got, tt.wantEmpty) {
+				t.Errorf("empty view = %q, want %q", got, tt.wantEmpty)
+			}
+
+			m := newTestModel(3)
+			m.SetKeys(keys.View)
+			m.SetFoldLines(2)
+			if got := strings.Join(m.lines, "\n"); !strings.Contains(got, tt.wantFold) {
+				t.Errorf("folded output does not say %q", tt.wantFold)
+			}
+		})
+	}
+}
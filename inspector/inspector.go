@@ -0,0 +1,164 @@
+package inspector
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cahier/store"
+	"cahier/theme"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MaxRuns is how many of the latest runs the inspector lists
+const MaxRuns = 5
+
+var (
+	paneStyle = lipgloss.NewStyle().
+			Padding(0, 1).
+			BorderStyle(lipgloss.RoundedBorder())
+	titleStyle   = lipgloss.NewStyle().Bold(true)
+	headingStyle = lipgloss.NewStyle().Bold(true)
+	faintStyle   = lipgloss.NewStyle().Faint(true)
+	outputStyle  lipgloss.Style
+	tagStyle     lipgloss.Style
+	statusStyles map[string]lipgloss.Style
+)
+
+// SetTheme colors the pane border, the status and the output
+func SetTheme(t theme.Theme) {
+	paneStyle = paneStyle.BorderForeground(lipgloss.Color(t.Border))
+	titleStyle = titleStyle.Foreground(lipgloss.Color(t.Accent))
+	faintStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Muted)).Faint(t.Faint)
+	outputStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Output))
+	tagStyle = t.Marked(t.Accent).Padding(0, 1)
+	statusStyles = map[string]lipgloss.Style{
+		store.StatusSuccess:         lipgloss.NewStyle().Foreground(lipgloss.Color(t.Success)),
+		store.StatusFailed:          lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error)),
+		store.StatusAssertionFailed: lipgloss.NewStyle().Foreground(lipgloss.Color(t.Warning)),
+		store.StatusRunning:         lipgloss.NewStyle().Foreground(lipgloss.Color(t.Info)),
+	}
+}
+
+// Cell is what the inspector shows about the selected cell
+type Cell struct {
+	Number  int // Shown number starting at 1, or 0 when no cell is selected
+	Command store.Command
+	Runs    []store.Run // Latest runs without their outputs, most recent first
+	Output  string      // Output of the last run, limited to the shown stream
+}
+
+// Render shows the status, tags, timing, latest runs and output of a cell in
+// a pane of the given size
+func Render(c Cell, width, height int) string {
+	inner := max(width-paneStyle.GetHorizontalFrameSize(), 10)
+	rows := max(height-paneStyle.GetVerticalFrameSize(), 1)
+	if c.Number == 0 {
+		return paneStyle.Width(inner + paneStyle.GetHorizontalPadding()).Height(rows).
+			Render(faintStyle.Render("No cell selected"))
+	}
+
+	lines := []string{titleStyle.Render(fmt.Sprintf("Cell %d", c.Number))}
+	lines = append(lines, strings.Split(describeStatus(c.Command), "\n")...)
+	if len(c.Command.Tags) > 0 {
+		chips := []string{}
+		for _, tag := range c.Command.Tags {
+			chips = append(chips, tagStyle.Render("#"+tag))
+		}
+		lines = append(lines, strings.Join(chips, " "))
+	}
+
+	lines = append(lines, "", headingStyle.Render("Runs"))
+	if len(c.Runs) == 0 {
+		lines = append(lines, faintStyle.Render("Never run"))
+	}
+	for _, run := range c.Runs {
+		lines = append(lines, describeRun(run))
+	}
+	if average, ok := averageDuration(c.Runs); ok {
+		lines = append(lines, faintStyle.Render(fmt.Sprintf("Average %s over %d runs", average, len(c.Runs))))
+	}
+
+	// The output takes the rest of the pane, showing its end like a terminal
+	if len(c.Runs) > 0 {
+		lines = append(lines, "", headingStyle.Render("Output"))
+		output := strings.Split(strings.TrimRight(c.Output, "\n"), "\n")
+		if c.Output == "" {
+			output = []string{faintStyle.Render("No output")}
+		} else {
+			for i, line := range output {
+				output[i] = outputStyle.Render(truncate(strings.ReplaceAll(line, "\t", "    "), inner))
+			}
+		}
+		if room := rows - len(lines); len(output) > room && room > 0 {
+			output = append([]string{faintStyle.Render(fmt.Sprintf("… %d lines above", len(output)-room+1))}, output[len(output)-room+1:]...)
+		}
+		lines = append(lines, output...)
+	}
+
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	return paneStyle.Width(inner + paneStyle.GetHorizontalPadding()).Height(rows).MaxHeight(height).
+		Render(lipgloss.NewStyle().MaxWidth(inner).Render(strings.Join(lines, "\n")))
+}
+
+// describeStatus tells how the last run of the command went
+func describeStatus(cmd store.Command) string {
+	style := statusStyles[cmd.Status]
+	var s string
+	switch cmd.Status {
+	case store.StatusRunning:
+		s = style.Render("Running")
+	case store.StatusSuccess:
+		s = style.Render("Succeeded")
+	case store.StatusFailed:
+		s = style.Render(fmt.Sprintf("Failed with exit code %d", cmd.ReturnCode))
+	case store.StatusAssertionFailed:
+		s = style.Render("Assertions failed")
+	default:
+		s = faintStyle.Render("Not run yet")
+	}
+	if cmd.StatusDetail != "" {
+		s += "\n" + style.Render(cmd.StatusDetail)
+	}
+	return s
+}
+
+// describeRun lists a run with when it started, how it ended and how long it took
+func describeRun(run store.Run) string {
+	status := statusStyles[store.StatusSuccess].Render(fmt.Sprintf("exit %d", run.ExitCode))
+	if run.ExitCode != 0 {
+		status = statusStyles[store.StatusFailed].Render(fmt.Sprintf("exit %d", run.ExitCode))
+	}
+	return fmt.Sprintf("%s  %s  %s", faintStyle.Render(ago(run.StartedAt)), status, run.Duration.Round(time.Millisecond))
+}
+
+func averageDuration(runs []store.Run) (time.Duration, bool) {
+	if len(runs) < 2 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, run := range runs {
+		total += run.Duration
+	}
+	return (total / time.Duration(len(runs))).Round(time.Millisecond), true
+}
+
+// ago tells how long ago a run started, to the second
+func ago(t time.Time) string {
+	elapsed := time.Since(t).Round(time.Second)
+	if elapsed >= 24*time.Hour {
+		return t.Format("2006-01-02 15:04")
+	}
+	return elapsed.String() + " ago"
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
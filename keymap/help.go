@@ -0,0 +1,89 @@
+package keymap
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// The modes implement help.KeyMap: the short help lists the main bindings,
+// shown in the footer, and the full help every binding of the mode
+
+func (k View) ShortHelp() []key.Binding {
+	return []key.Binding{k.New, k.Edit, k.Fold, k.Undo, k.Search, k.Palette, k.Help}
+}
+
+func (k View) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.New, k.Edit, k.External, k.Delete, k.MoveUp, k.MoveDown, k.Undo, k.Redo, k.RunAll, k.Palette},
+		{k.Fold, k.Pager, k.Stream, k.Search, k.NextMatch, k.PrevMatch, k.Filter, k.Clear, k.Tags, k.Assert, k.Normalize},
+		{k.Lint, k.CopyCommand, k.CopyOutput, k.CopyMarkdown, k.Revisions, k.Diff, k.OlderRun, k.NewerRun, k.Golden, k.Split, k.Help},
+	}
+}
+
+// Bound reports whether a key is bound to one of the actions of the mode,
+// which the full help lists every one of
+func (k View) Bound(msg fmt.Stringer) bool {
+	for _, column := range k.FullHelp() {
+		if key.Matches(msg, column...) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k Edit) ShortHelp() []key.Binding {
+	return []key.Binding{k.Run, k.Save, k.Cancel}
+}
+
+func (k Edit) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+func (k NewCommand) ShortHelp() []key.Binding {
+	return []key.Binding{k.Run, k.Cancel}
+}
+
+func (k NewCommand) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+func (k Prompt) ShortHelp() []key.Binding {
+	return []key.Binding{k.Submit, k.Cancel}
+}
+
+func (k Prompt) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+func (k Palette) ShortHelp() []key.Binding {
+	return []key.Binding{k.Go, k.Run, k.Copy, k.Close}
+}
+
+func (k Palette) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Up, k.Down, k.Go}, {k.Run, k.Copy, k.Close}}
+}
+
+func (k Revisions) ShortHelp() []key.Binding {
+	return []key.Binding{k.Restore, k.Base, k.SideBySide, k.Close}
+}
+
+func (k Revisions) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Up, k.Down, k.Base}, {k.SideBySide, k.Restore, k.Close}}
+}
+
+func (k Pager) ShortHelp() []key.Binding {
+	return []key.Binding{k.Search, k.Close}
+}
+
+func (k Pager) FullHelp() [][]key.Binding {
+	return [][]key.Binding{{k.Search, k.NextMatch, k.PrevMatch}, {k.Top, k.Bottom, k.Close}}
+}
+
+func (k Confirm) ShortHelp() []key.Binding {
+	return []key.Binding{k.Yes, k.No}
+}
+
+func (k Confirm) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
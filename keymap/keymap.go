@@ -0,0 +1,440 @@
+package keymap
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+type Global struct {
+	Quit key.Binding
+}
+
+type View struct {
+	Up           key.Binding
+	Down         key.Binding
+	New          key.Binding
+	Edit         key.Binding
+	External     key.Binding
+	Fold         key.Binding
+	Pager        key.Binding
+	Stream       key.Binding
+	Search       key.Binding
+	NextMatch    key.Binding
+	PrevMatch    key.Binding
+	Filter       key.Binding
+	Clear        key.Binding
+	Tags         key.Binding
+	Assert       key.Binding
+	Normalize    key.Binding
+	Lint         key.Binding
+	CopyCommand  key.Binding
+	CopyOutput   key.Binding
+	CopyMarkdown key.Binding
+	Revisions    key.Binding
+	Diff         key.Binding
+	OlderRun     key.Binding
+	NewerRun     key.Binding
+	Golden       key.Binding
+	Delete       key.Binding
+	MoveUp       key.Binding
+	MoveDown     key.Binding
+	Undo         key.Binding
+	Redo         key.Binding
+	RunAll       key.Binding
+	Palette      key.Binding
+	Split        key.Binding
+	Help         key.Binding
+}
+
+type Edit struct {
+	Run    key.Binding
+	Save   key.Binding
+	Cancel key.Binding
+}
+
+type NewCommand struct {
+	Run    key.Binding
+	Cancel key.Binding
+}
+
+type Prompt struct {
+	Submit key.Binding
+	Cancel key.Binding
+}
+
+type Palette struct {
+	Up    key.Binding
+	Down  key.Binding
+	Go    key.Binding
+	Run   key.Binding
+	Copy  key.Binding
+	Close key.Binding
+}
+
+type Revisions struct {
+	Up         key.Binding
+	Down       key.Binding
+	Base       key.Binding
+	SideBySide key.Binding
+	Restore    key.Binding
+	Close      key.Binding
+}
+
+type Pager struct {
+	Search    key.Binding
+	NextMatch key.Binding
+	PrevMatch key.Binding
+	Top       key.Binding
+	Bottom    key.Binding
+	Close     key.Binding
+}
+
+type Confirm struct {
+	Yes key.Binding
+	No  key.Binding
+}
+
+type Help struct {
+	Close key.Binding
+}
+
+// KeyMap holds the key bindings of every mode
+type KeyMap struct {
+	Global     Global
+	View       View
+	Edit       Edit
+	NewCommand NewCommand
+	Prompt     Prompt
+	Palette    Palette
+	Revisions  Revisions
+	Pager      Pager
+	Confirm    Confirm
+	Help       Help
+}
+
+var presets = map[string]func() KeyMap{
+	"default": Default,
+	"vim":     Vim,
+	"emacs":   Emacs,
+}
+
+// Presets returns the names of the built-in keymaps
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the keymap of a preset with some actions bound to other keys.
+// Bindings are keyed by action name, such as "view.new", and space is written "space".
+func New(preset string, bindings map[string][]string) (KeyMap, error) {
+	newKeyMap, ok := presets[preset]
+	if !ok {
+		return KeyMap{}, fmt.Errorf("unknown keymap preset %q, expected one of %s", preset, strings.Join(Presets(), ", "))
+	}
+	k := newKeyMap()
+
+	actions := k.actions()
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		binding, ok := actions[name]
+		if !ok {
+			return KeyMap{}, fmt.Errorf("unknown action %q in key bindings", name)
+		}
+		keys := bindings[name]
+		if len(keys) == 0 {
+			return KeyMap{}, fmt.Errorf("no key for action %q", name)
+		}
+		keys = slices.Clone(keys)
+		for i, k := range keys {
+			if k == "space" {
+				keys[i] = " "
+			}
+		}
+		binding.SetKeys(keys...)
+		binding.SetHelp(helpKey(keys), binding.Help().Desc)
+	}
+
+	if err := k.checkConflicts(); err != nil {
+		return KeyMap{}, err
+	}
+	return k, nil
+}
+
+// Actions returns the names of the actions that can be bound to other keys
+func Actions() []string {
+	k := Default()
+	names := []string{}
+	for name := range k.actions() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// actions returns the bindings of the keymap by action name
+func (k *KeyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"global.quit": &k.Global.Quit,
+
+		"view.up":            &k.View.Up,
+		"view.down":          &k.View.Down,
+		"view.new":           &k.View.New,
+		"view.edit":          &k.View.Edit,
+		"view.external":      &k.View.External,
+		"view.fold":          &k.View.Fold,
+		"view.pager":         &k.View.Pager,
+		"view.stream":        &k.View.Stream,
+		"view.search":        &k.View.Search,
+		"view.next_match":    &k.View.NextMatch,
+		"view.prev_match":    &k.View.PrevMatch,
+		"view.filter":        &k.View.Filter,
+		"view.clear":         &k.View.Clear,
+		"view.tags":          &k.View.Tags,
+		"view.assert":        &k.View.Assert,
+		"view.normalize":     &k.View.Normalize,
+		"view.lint":          &k.View.Lint,
+		"view.copy_command":  &k.View.CopyCommand,
+		"view.copy_output":   &k.View.CopyOutput,
+		"view.copy_markdown": &k.View.CopyMarkdown,
+		"view.revisions":     &k.View.Revisions,
+		"view.diff":          &k.View.Diff,
+		"view.older_run":     &k.View.OlderRun,
+		"view.newer_run":     &k.View.NewerRun,
+		"view.golden":        &k.View.Golden,
+		"view.delete":        &k.View.Delete,
+		"view.move_up":       &k.View.MoveUp,
+		"view.move_down":     &k.View.MoveDown,
+		"view.undo":          &k.View.Undo,
+		"view.redo":          &k.View.Redo,
+		"view.run_all":       &k.View.RunAll,
+		"view.palette":       &k.View.Palette,
+		"view.split":         &k.View.Split,
+		"view.help":          &k.View.Help,
+
+		"edit.run":    &k.Edit.Run,
+		"edit.save":   &k.Edit.Save,
+		"edit.cancel": &k.Edit.Cancel,
+
+		"new.run":    &k.NewCommand.Run,
+		"new.cancel": &k.NewCommand.Cancel,
+
+		"prompt.submit": &k.Prompt.Submit,
+		"prompt.cancel": &k.Prompt.Cancel,
+
+		"palette.up":    &k.Palette.Up,
+		"palette.down":  &k.Palette.Down,
+		"palette.go":    &k.Palette.Go,
+		"palette.run":   &k.Palette.Run,
+		"palette.copy":  &k.Palette.Copy,
+		"palette.close": &k.Palette.Close,
+
+		"revisions.up":           &k.Revisions.Up,
+		"revisions.down":         &k.Revisions.Down,
+		"revisions.base":         &k.Revisions.Base,
+		"revisions.side_by_side": &k.Revisions.SideBySide,
+		"revisions.restore":      &k.Revisions.Restore,
+		"revisions.close":        &k.Revisions.Close,
+
+		"pager.search":     &k.Pager.Search,
+		"pager.next_match": &k.Pager.NextMatch,
+		"pager.prev_match": &k.Pager.PrevMatch,
+		"pager.top":        &k.Pager.Top,
+		"pager.bottom":     &k.Pager.Bottom,
+		"pager.close":      &k.Pager.Close,
+
+		"confirm.yes": &k.Confirm.Yes,
+		"confirm.no":  &k.Confirm.No,
+
+		"help.close": &k.Help.Close,
+	}
+}
+
+// checkConflicts reports a key bound to two actions of the same mode, or to an
+// action of a mode and a global one
+func (k *KeyMap) checkConflicts() error {
+	actions := k.actions()
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bound := map[string]string{} // Action by mode and key
+	for _, name := range names {
+		mode, _, _ := strings.Cut(name, ".")
+		for _, keyName := range actions[name].Keys() {
+			if other, ok := bound[mode+" "+keyName]; ok {
+				return fmt.Errorf("key %q is bound to both %s and %s", keyName, other, name)
+			}
+			bound[mode+" "+keyName] = name
+		}
+	}
+
+	for _, keyName := range k.Global.Quit.Keys() {
+		for _, name := range names {
+			if !strings.HasPrefix(name, "global.") && slices.Contains(actions[name].Keys(), keyName) {
+				return fmt.Errorf("key %q is bound to both global.quit and %s", keyName, name)
+			}
+		}
+	}
+	return nil
+}
+
+func bind(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(helpKey(keys), desc))
+}
+
+// helpKey shows the keys of a binding the way they are typed
+func helpKey(keys []string) string {
+	shown := make([]string, len(keys))
+	for i, k := range keys {
+		if k == " " {
+			k = "space"
+		}
+		shown[i] = k
+	}
+	return strings.Join(shown, "/")
+}
+
+// Default returns the keymap cahier started with
+func Default() KeyMap {
+	return KeyMap{
+		Global: Global{
+			Quit: bind("Quit", "ctrl+d"),
+		},
+		View: View{
+			Up:           bind("Previous cell", "up", "k"),
+			Down:         bind("Next cell", "down", "j"),
+			New:          bind("New", "n"),
+			Edit:         bind("Edit", "enter"),
+			External:     bind("Edit in $EDITOR", "e"),
+			Fold:         bind("Fold output", "o"),
+			Pager:        bind("Page output", "p"),
+			Stream:       bind("Cycle streams", "s"),
+			Search:       bind("Search", "/"),
+			NextMatch:    bind("Next match", "tab"),
+			PrevMatch:    bind("Previous match", "shift+tab"),
+			Filter:       bind("Filter", "f"),
+			Clear:        bind("Clear search and filter", "esc"),
+			Tags:         bind("Tags", "t"),
+			Assert:       bind("Assertions", "a"),
+			Normalize:    bind("Normalizers", "N"),
+			Lint:         bind("Toggle checks", "L"),
+			CopyCommand:  bind("Copy command", "y"),
+			CopyOutput:   bind("Copy output", "Y"),
+			CopyMarkdown: bind("Copy as Markdown", "m"),
+			Revisions:    bind("Revisions", "v"),
+			Diff:         bind("Output diff", "D"),
+			OlderRun:     bind("Older run", "["),
+			NewerRun:     bind("Newer run", "]"),
+			Golden:       bind("Pin/unpin golden", "G"),
+			Delete:       bind("Delete", "d"),
+			MoveUp:       bind("Move up", "K"),
+			MoveDown:     bind("Move down", "J"),
+			Undo:         bind("Undo", "u"),
+			Redo:         bind("Redo", "ctrl+r"),
+			RunAll:       bind("Run all", "R"),
+			Palette:      bind("Palette", "ctrl+p"),
+			Split:        bind("Split view", "|"),
+			Help:         bind("Help", "?"),
+		},
+		Edit: Edit{
+			Run:    bind("Run", "ctrl+r"),
+			Save:   bind("Save", "ctrl+s"),
+			Cancel: bind("Cancel", "esc"),
+		},
+		NewCommand: NewCommand{
+			Run:    bind("Run", "ctrl+r"),
+			Cancel: bind("Cancel", "esc"),
+		},
+		Prompt: Prompt{
+			Submit: bind("Apply", "enter"),
+			Cancel: bind("Cancel", "esc"),
+		},
+		Palette: Palette{
+			Up:    bind("Previous command", "up", "ctrl+k"),
+			Down:  bind("Next command", "down", "ctrl+j"),
+			Go:    bind("Go to cell", "enter"),
+			Run:   bind("Run", "ctrl+r"),
+			Copy:  bind("Copy to new cell", "ctrl+n"),
+			Close: bind("Close", "esc"),
+		},
+		Revisions: Revisions{
+			Up:         bind("Previous revision", "up", "k"),
+			Down:       bind("Next revision", "down", "j"),
+			Base:       bind("Compare with this one", " ", "b"),
+			SideBySide: bind("Side by side", "s"),
+			Restore:    bind("Restore", "enter"),
+			Close:      bind("Close", "esc"),
+		},
+		Pager: Pager{
+			Search:    bind("Search", "/"),
+			NextMatch: bind("Next match", "n"),
+			PrevMatch: bind("Previous match", "N"),
+			Top:       bind("Top", "g", "home"),
+			Bottom:    bind("Bottom", "G", "end"),
+			Close:     bind("Close", "esc"),
+		},
+		Confirm: Confirm{
+			Yes: bind("Run", "y"),
+			No:  bind("Cancel", "n", "esc"),
+		},
+		Help: Help{
+			Close: bind("Close", "?", "esc", "q"),
+		},
+	}
+}
+
+// Vim returns the default keymap with keys closer to vim: o opens a new cell,
+// n and N jump between search matches and x deletes
+func Vim() KeyMap {
+	k := Default()
+	k.View.New = bind("New", "o")
+	k.View.Edit = bind("Edit", "enter", "i")
+	k.View.Fold = bind("Fold output", " ", "z")
+	k.View.NextMatch = bind("Next match", "n", "tab")
+	k.View.PrevMatch = bind("Previous match", "N", "shift+tab")
+	k.View.Normalize = bind("Normalizers", "=")
+	k.View.Delete = bind("Delete", "x", "d")
+	k.Pager.Close = bind("Close", "esc", "q")
+	k.Revisions.Close = bind("Close", "esc", "q")
+	return k
+}
+
+// Emacs returns the default keymap with keys closer to emacs: ctrl+p and ctrl+n
+// move between cells, ctrl+s searches and ctrl+g cancels
+func Emacs() KeyMap {
+	k := Default()
+	k.View.Up = bind("Previous cell", "up", "ctrl+p")
+	k.View.Down = bind("Next cell", "down", "ctrl+n")
+	k.View.Search = bind("Search", "ctrl+s", "/")
+	k.View.Clear = bind("Clear search and filter", "ctrl+g", "esc")
+	k.View.Undo = bind("Undo", "ctrl+_", "u")
+	k.View.Palette = bind("Palette", "alt+x")
+	k.Edit.Cancel = bind("Cancel", "ctrl+g", "esc")
+	k.NewCommand.Cancel = bind("Cancel", "ctrl+g", "esc")
+	k.Prompt.Cancel = bind("Cancel", "ctrl+g", "esc")
+	k.Palette.Close = bind("Close", "ctrl+g", "esc")
+	k.Revisions.Up = bind("Previous revision", "up", "ctrl+p")
+	k.Revisions.Down = bind("Next revision", "down", "ctrl+n")
+	k.Revisions.Close = bind("Close", "ctrl+g", "esc")
+	k.Pager.Search = bind("Search", "ctrl+s", "/")
+	k.Pager.Top = bind("Top", "alt+<", "g", "home")
+	k.Pager.Bottom = bind("Bottom", "alt+>", "G", "end")
+	k.Pager.Close = bind("Close", "ctrl+g", "esc")
+	k.Confirm.No = bind("Cancel", "n", "ctrl+g", "esc")
+	k.Help.Close = bind("Close", "ctrl+g", "esc", "q")
+	return k
+}
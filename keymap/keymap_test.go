@@ -0,0 +1,86 @@
+package keymap
+
+import (
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   string
+		bindings map[string][]string
+		wantErr  string
+	}{
+		{"default", "default", nil, ""},
+		{"vim", "vim", nil, ""},
+		{"emacs", "emacs", nil, ""},
+		{"unknown preset", "nano", nil, `unknown keymap preset "nano", expected one of default, emacs, vim`},
+		{"unknown action", "default", map[string][]string{"view.fly": {"F"}}, `unknown action "view.fly" in key bindings`},
+		{"no key", "default", map[string][]string{"view.new": {}}, `no key for action "view.new"`},
+		{"same mode", "default", map[string][]string{"view.new": {"d"}},
+			`key "d" is bound to both view.delete and view.new`},
+		{"swapped keys", "default", map[string][]string{"view.new": {"d"}, "view.delete": {"n"}}, ""},
+		{"other mode", "default", map[string][]string{"edit.save": {"n"}}, ""},
+		{"space", "vim", map[string][]string{"view.new": {"space"}},
+			`key " " is bound to both view.fold and view.new`},
+		{"quit key", "default", map[string][]string{"view.new": {"ctrl+d"}},
+			`key "ctrl+d" is bound to both global.quit and view.new`},
+		{"quit on mode key", "default", map[string][]string{"global.quit": {"q"}},
+			`key "q" is bound to both global.quit and help.close`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.preset, tt.bindings)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("New(%q, %v) = %v", tt.preset, tt.bindings, err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("New(%q, %v) error = %v, want %s", tt.preset, tt.bindings, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewBindings(t *testing.T) {
+	k, err := New("default", map[string][]string{"view.fold": {"space", "o"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := k.View.Fold.Keys(), []string{" ", "o"}; !slices.Equal(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+	if help := k.View.Fold.Help(); help.Key != "space/o" || help.Desc != "Fold output" {
+		t.Errorf("help = %q %q, want the keys as typed and the same description", help.Key, help.Desc)
+	}
+	if got := Default().View.Fold.Keys(); !slices.Equal(got, []string{"o"}) {
+		t.Errorf("the default keymap changed to %q", got)
+	}
+}
+
+func TestViewBound(t *testing.T) {
+	tests := []struct {
+		preset string
+		key    tea.KeyMsg
+		want   bool
+	}{
+		{"default", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")}, true},
+		{"default", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")}, true},
+		{"default", tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}, false},
+		{"vim", tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}, true},
+		{"default", tea.KeyMsg{Type: tea.KeyPgDown}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.preset+" "+tt.key.String(), func(t *testing.T) {
+			k, err := New(tt.preset, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := k.View.Bound(tt.key); got != tt.want {
+				t.Errorf("Bound(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+
+	"cahier/lint"
+	"cahier/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lintSetting turns the checks of commands off for a notebook when set to "off"
+const lintSetting = "lint"
+
+func lintEnabled(db *store.Store) bool {
+	value, err := db.GetSetting(lintSetting)
+	if err != nil {
+		log.Printf("Failed to get lint setting: %v", err)
+	}
+	return value != "off"
+}
+
+// toggleLint turns the checks of commands on or off for the notebook
+func toggleLint(m Model) (Model, tea.Cmd) {
+	enabled := !m.cmdsHistory.Linting()
+	value := "on"
+	if !enabled {
+		value = "off"
+	}
+	if err := m.store.SetSetting(lintSetting, value); err != nil {
+		log.Printf("Failed to save lint setting: %v", err)
+	}
+
+	m.cmdsHistory.SetLint(enabled)
+	return showFlash(m, "Shell checks "+value)
+}
+
+// checkBeforeRun returns the first syntax error of a command about to run in
+// a notebook, which would only make it fail, if the checks of the notebook are on.
+// Every way of running a cell goes through it.
+func checkBeforeRun(db *store.Store, command string) (lint.Issue, bool) {
+	if !lintEnabled(db) {
+		return lint.Issue{}, false
+	}
+	return lint.FirstError(lint.Check(command))
+}
+
+// notRun tells why a command was not run
+func notRun(m Model, issue lint.Issue) (Model, tea.Cmd) {
+	return showFlash(m, errorStyle.Render("Not run, "+issue.String()))
+}
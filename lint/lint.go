@@ -0,0 +1,160 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+const (
+	Error   = "error"
+	Warning = "warning"
+)
+
+// Issue is a problem found in a command before running it
+type Issue struct {
+	Severity string // Error for syntax errors, which would make the command fail, else Warning
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Severity, i.Message)
+}
+
+// Check parses a command as bash and looks for common pitfalls. A command
+// with a syntax error only gets that error.
+func Check(command string) []Issue {
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(command), "")
+	if err != nil {
+		var parseErr syntax.ParseError
+		if errors.As(err, &parseErr) {
+			return []Issue{{Error, int(parseErr.Pos.Line()), int(parseErr.Pos.Col()), parseErr.Text}}
+		}
+		return []Issue{{Severity: Error, Line: 1, Column: 1, Message: err.Error()}}
+	}
+
+	issues := []Issue{}
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok {
+			issues = append(issues, checkCall(call)...)
+		}
+		return true
+	})
+	return issues
+}
+
+func warning(pos syntax.Pos, format string, args ...any) Issue {
+	return Issue{Warning, int(pos.Line()), int(pos.Col()), fmt.Sprintf(format, args...)}
+}
+
+// checkCall looks for the pitfalls of a simple command and its arguments
+func checkCall(call *syntax.CallExpr) []Issue {
+	issues := []Issue{}
+
+	// Expansions are split and globbed in arguments, not in assignments
+	for _, arg := range call.Args {
+		for _, part := range arg.Parts {
+			switch part := part.(type) {
+			case *syntax.ParamExp:
+				if splits(part) {
+					issues = append(issues, warning(part.Pos(),
+						"unquoted %s is split on spaces and globbed, quote it", expansionName(part)))
+				}
+			case *syntax.CmdSubst:
+				issues = append(issues, warning(part.Pos(),
+					"unquoted command substitution is split on spaces and globbed, quote it"))
+			}
+		}
+	}
+
+	if len(call.Args) > 0 && call.Args[0].Lit() == "rm" && recursiveForce(call.Args[1:]) {
+		for _, arg := range call.Args[1:] {
+			if param := rootedParam(arg); param != nil && (param.Exp == nil || param.Exp.Op != syntax.ErrorUnsetOrNull) {
+				issues = append(issues, warning(arg.Pos(),
+					"rm -rf on a path starting with %s removes from / if it is empty, use ${%s:?}",
+					expansionName(param), param.Param.Value))
+			}
+		}
+	}
+
+	return issues
+}
+
+// splits reports whether an unquoted parameter expansion may expand to
+// several words. Special parameters holding numbers never do.
+func splits(param *syntax.ParamExp) bool {
+	if param.Length || param.Param == nil {
+		return false
+	}
+	switch param.Param.Value {
+	case "#", "?", "$", "!":
+		return false
+	}
+	return true
+}
+
+func expansionName(param *syntax.ParamExp) string {
+	if param.Short {
+		return "$" + param.Param.Value
+	}
+	return "${" + param.Param.Value + "}"
+}
+
+// recursiveForce reports whether rm options include both -r and -f
+func recursiveForce(args []*syntax.Word) bool {
+	recursive, force := false, false
+	for _, arg := range args {
+		option := arg.Lit()
+		switch {
+		case option == "--recursive":
+			recursive = true
+		case option == "--force":
+			force = true
+		case strings.HasPrefix(option, "-") && !strings.HasPrefix(option, "--"):
+			recursive = recursive || strings.ContainsAny(option, "rR")
+			force = force || strings.Contains(option, "f")
+		}
+	}
+	return recursive && force
+}
+
+// rootedParam returns the parameter expansion a path such as $DIR/ or
+// "$DIR"/build starts with, which is / when the parameter is empty
+func rootedParam(word *syntax.Word) *syntax.ParamExp {
+	parts := word.Parts
+	if quoted, ok := parts[0].(*syntax.DblQuoted); ok && len(quoted.Parts) > 0 {
+		parts = append(append([]syntax.WordPart{}, quoted.Parts...), parts[1:]...)
+	}
+	if len(parts) < 2 {
+		return nil
+	}
+
+	param, ok := parts[0].(*syntax.ParamExp)
+	if !ok || param.Param == nil {
+		return nil
+	}
+	if lit, ok := parts[1].(*syntax.Lit); ok && strings.HasPrefix(lit.Value, "/") {
+		return param
+	}
+	if quoted, ok := parts[1].(*syntax.DblQuoted); ok && len(quoted.Parts) > 0 {
+		if lit, ok := quoted.Parts[0].(*syntax.Lit); ok && strings.HasPrefix(lit.Value, "/") {
+			return param
+		}
+	}
+	return nil
+}
+
+// FirstError returns the first of the issues that would stop the command from
+// running, if any
+func FirstError(issues []Issue) (Issue, bool) {
+	for _, issue := range issues {
+		if issue.Severity == Error {
+			return issue, true
+		}
+	}
+	return Issue{}, false
+}
@@ -0,0 +1,45 @@
+package lint
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		command  string
+		severity []string // Severity of every issue found
+	}{
+		{`echo "$HOME"`, nil},
+		{`ls $dir`, []string{Warning}},
+		{`echo $(date)`, []string{Warning}},
+		{`name=$1`, nil},
+		{`rm -rf $dir/sub`, []string{Warning, Warning}},
+		{`rm -rf "${dir:?}/sub"`, nil},
+		{`if true; then echo`, []string{Error}},
+		{`echo "unterminated`, []string{Error}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			issues := Check(tt.command)
+			if len(issues) != len(tt.severity) {
+				t.Fatalf("Check(%q) = %v, want %d issues", tt.command, issues, len(tt.severity))
+			}
+			for i, issue := range issues {
+				if issue.Severity != tt.severity[i] {
+					t.Errorf("issue %d of %q is an %s, want %s", i, tt.command, issue.Severity, tt.severity[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFirstError(t *testing.T) {
+	if _, found := FirstError(Check(`ls $dir`)); found {
+		t.Error("warnings should not stop a run")
+	}
+	issue, found := FirstError(Check("echo ok\nif true; then"))
+	if !found {
+		t.Fatal("want the syntax error")
+	}
+	if issue.Line != 2 {
+		t.Errorf("error on line %d, want 2", issue.Line)
+	}
+}
@@ -1,21 +1,75 @@
 package main
 
 import (
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 
+	"cahier/config"
 	"cahier/store"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
 	store := &store.Store{}
-	if err := store.Init("./cahier.db"); err != nil {
+	if err := store.Init(cfg.Database); err != nil {
 		log.Fatalf("Failed to initialize db: %v", err)
 	}
+	defer store.Close()
+
+	// Subcommands run without the interface
+	if len(os.Args) > 1 {
+		// The theme was checked with the config, and colors diffs like in the interface
+		if t, err := cfg.For(store.Name()).LoadTheme(); err == nil {
+			applyTheme(t)
+		}
+		if err := runCLI(store, cfg, os.Args[1:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
 
-	m := NewModel(store)
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	m := NewModel(store, cfg)
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if cfg.Mouse {
+		options = append(options, tea.WithMouseCellMotion())
+	}
+
+	// Logs would garble the interface, so they go to a file while it runs
+	if logFile, err := openLog(); err == nil {
+		defer logFile.Close()
+	} else {
+		log.SetOutput(io.Discard)
+	}
+	p := tea.NewProgram(m, options...)
+	final, err := p.Run()
+	log.SetOutput(os.Stderr)
+	log.SetPrefix("")
+	if err != nil {
 		log.Fatalf("Failed to run the program: %v", err)
 	}
+
+	// The interface may have switched to another notebook
+	if final, ok := final.(Model); ok {
+		final.store.Close()
+	}
+}
+
+// openLog sends the log to cahier.log in the user cache directory
+func openLog() (*os.File, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "cahier")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return tea.LogToFile(filepath.Join(dir, "cahier.log"), "cahier")
 }
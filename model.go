@@ -1,16 +1,26 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"cahier/assert"
+	"cahier/config"
 	"cahier/executor"
 	"cahier/history"
+	"cahier/keymap"
+	"cahier/pager"
+	"cahier/palette"
+	"cahier/snapshot"
 	"cahier/store"
+	"cahier/theme"
 
 	ta "cahier/textarea"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -20,34 +30,86 @@ const (
 	ViewMode       Status = iota
 	EditMode              // For inline editing of existing commands
 	NewCommandMode        // For creating new commands
+	SearchMode            // For typing a search query
+	TagMode               // For editing the tags of the selected command
+	FilterMode            // For typing a filter on tags and status
+	AssertMode            // For editing the assertions of the selected command
+	NormalizeMode         // For editing the snapshot normalizers of the selected command
+	PaletteMode           // For picking a command from any notebook
+	RevisionsMode         // For browsing the revisions of the selected command
+	PagerMode             // For scrolling through the output of the selected command
+	ConfirmMode           // For confirming a guarded command before it runs
+	HelpMode              // For listing the key bindings
 )
 
 type Model struct {
-	currentMode Status
-	store       *store.Store
-	cmds        []store.Command
-	currentCmd  store.Command
-	currentIdx  int
-	textarea    textarea.Model
-	cmdsHistory history.Model
-	width       int
-	height      int
+	currentMode    Status
+	store          *store.Store
+	cmds           []store.Command
+	currentCmd     store.Command
+	currentIdx     int
+	textarea       textarea.Model
+	cmdsHistory    history.Model
+	prompt         textinput.Model // Single line input shared by the search, tag and filter modes
+	promptErr      string
+	search         searchState
+	palette        palette.Model
+	revisions      revisionsState
+	outputDiff     *outputDiff // Output diff shown in the history pane, if any
+	pager          pager.Model
+	config         config.Config // Settings of every notebook, see settings for the current one
+	keys           keymap.KeyMap
+	theme          theme.Theme
+	help           helpState
+	split          bool // Whether the inspector is shown next to the cells on wide terminals
+	inspector      inspectorState
+	runAll         *runAllState  // Run of every cell in progress, if any
+	lastRunAll     *store.RunAll // Last run of every cell of the notebook, if any
+	cwd            string        // Directory the commands run in, shown in the status bar
+	ticking        bool          // Whether the animation tick is scheduled
+	confirm        *confirmState // Guarded command waiting for a confirmation, if any
+	flash          string        // Transient message shown in the footer
+	flashID        int           // Identifies the flash message so that only the latest one expires it
+	escapeSequence string        // Terminal escape written along with the view, see writeEscape
+	escapeID       int           // Identifies the escape so that only the latest one is removed
+	lastClick      click         // Previous click, to tell double clicks
+	width          int
+	height         int
 }
 
-func NewModel(db *store.Store) Model {
+// textareaChrome is the width taken around the textarea by its label, borders and padding
+const textareaChrome = 4 + 1 + 4 + 2
+
+func NewModel(db *store.Store, cfg config.Config) Model {
 	cmds, err := db.GetCommands()
 	if err != nil {
 		log.Fatalf("Failed to get commands: %v", err)
 	}
 
+	outputs, err := db.GetLastRuns()
+	if err != nil {
+		log.Fatalf("Failed to get outputs: %v", err)
+	}
+
 	currentIdx := len(cmds) - 1
 	cmdsHistory := history.NewModel(cmds)
+	cmdsHistory.SetOutputs(outputs)
+	cmdsHistory.SetLint(lintEnabled(db))
+	cmdsHistory.SetFoldLines(cfg.For(db.Name()).Output.FoldLines)
 	cmdsHistory.Select(currentIdx)
 	cmdsHistory.SetHeight(24, false)
 
-	textarea := ta.NewWithWidth(80 - 4 - 1 - 4 - 2)
+	textarea := ta.NewWithWidth(80 - textareaChrome)
+
+	keys, err := keymap.New(cfg.Keys.Preset, cfg.Keys.Bindings)
+	if err != nil {
+		log.Fatalf("Invalid key bindings: %v", err)
+	}
+	cmdsHistory.SetKeys(keys.View)
+
+	prompt := textinput.New()
 
-	return Model{
+	m := Model{
 		currentMode: ViewMode,
 		store:       db,
 		cmds:        cmds,
@@ -55,18 +117,39 @@ func NewModel(db *store.Store) Model {
 		currentIdx:  currentIdx,
 		textarea:    textarea,
 		cmdsHistory: cmdsHistory,
+		prompt:      prompt,
+		config:      cfg,
+		keys:        keys,
+		split:       cfg.Split,
+		lastRunAll:  loadLastRunAll(db),
+		cwd:         workingDir(),
 		width:       80, // Default width
 		height:      24, // Default height
 	}
+
+	m, err = loadTheme(m)
+	if err != nil {
+		log.Fatalf("Invalid theme: %v", err)
+	}
+	m.ticking = animates(m)
+	return m
 }
 
 func (m Model) Init() tea.Cmd {
 	// Start ticker for rainbow animation
-	return tickCmd()
+	if !m.ticking {
+		return nil
+	}
+	return tickCmd(m.settings().Tick.Duration)
+}
+
+// settings returns the settings of the current notebook
+func (m Model) settings() config.Config {
+	return m.config.For(m.store.Name())
 }
 
-func tickCmd() tea.Cmd {
-	return tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg {
+func tickCmd(interval time.Duration) tea.Cmd {
+	return tea.Tick(interval, func(t time.Time) tea.Msg {
 		return tickMsg(t)
 	})
 }
@@ -78,11 +161,17 @@ type execStartMsg struct {
 }
 
 type execCompleteMsg struct {
-	cmdID    int64
-	exitCode int
+	cmdID  int64
+	result executor.Result
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	m, inspectorCmd := refreshInspector(m)
+	return m, tea.Batch(cmd, inspectorCmd)
+}
+
+func (m Model) update(msg tea.Msg) (Model, tea.Cmd) {
 	var (
 		cmds []tea.Cmd
 		cmd  tea.Cmd
@@ -90,88 +179,169 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tickMsg:
-		// Update rainbow animation
-		m.cmdsHistory.UpdateAnimation()
-		cmds = append(cmds, tickCmd())
+		// Update rainbow animation, which stops in notebooks without it
+		m.ticking = animates(m)
+		if m.ticking {
+			m.cmdsHistory.UpdateAnimation()
+			cmds = append(cmds, tickCmd(m.settings().Tick.Duration))
+		}
 
 	case tea.WindowSizeMsg:
 		// Update terminal dimensions
 		m.width = msg.Width
 		m.height = msg.Height
-		m.cmdsHistory.SetWidth(msg.Width)
+		m.cmdsHistory.SetWidth(historyWidth(m))
 		m.cmdsHistory.SetHeight(msg.Height, m.currentMode == NewCommandMode)
-		m.textarea.SetWidth(msg.Width - 4 - 1 - 4 - 2)
+		m.textarea.SetWidth(msg.Width - textareaChrome)
+		m.palette.SetWidth(msg.Width)
+		m.pager.SetSize(msg.Width, msg.Height-4)
+		m.help.viewport.Width = msg.Width
+		m.help.viewport.Height = msg.Height - 4
 
 	case tea.KeyMsg:
-		key := msg.String()
-
 		// Global keybindings
-		switch key {
-		case "ctrl+d":
+		if key.Matches(msg, m.keys.Global.Quit) {
 			return m, tea.Quit
 		}
 
 		// Mode-dependant keybindings
 		switch m.currentMode {
 		case ViewMode:
-			m.cmdsHistory, cmd = m.cmdsHistory.Update(msg)
-			cmds = append(cmds, cmd)
-			return HandleViewModeKey(m, key)
+			// Only the keys bound to no action scroll the cells, else f
+			// would filter and page down at once
+			if !m.keys.View.Bound(msg) {
+				m.cmdsHistory, cmd = m.cmdsHistory.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+			return HandleViewModeKey(m, msg)
 
 		case EditMode:
-			switch key {
-			case "ctrl+r", "ctrl+s", "esc":
+			k := m.keys.Edit
+			if key.Matches(msg, k.Run, k.Save, k.Cancel) {
 				// Handle these without passing to textarea
-				return HandleEditModeKey(m, key)
-			default:
-				// Pass other keys to the history's textarea
-				m.cmdsHistory, cmd = m.cmdsHistory.Update(msg)
-				cmds = append(cmds, cmd)
-				return m, tea.Batch(cmds...)
+				return HandleEditModeKey(m, msg)
 			}
+			// Pass other keys to the history's textarea
+			m.cmdsHistory, cmd = m.cmdsHistory.Update(msg)
+			cmds = append(cmds, cmd)
+			return m, tea.Batch(cmds...)
 
 		case NewCommandMode:
-			switch key {
-			case "ctrl+r", "esc":
+			k := m.keys.NewCommand
+			if key.Matches(msg, k.Run, k.Cancel) {
 				// Handle these without passing to textarea
-				return HandleNewCommandModeKey(m, key)
-			default:
-				// Pass other keys to textarea in new command mode
-				m.textarea, cmd = m.textarea.Update(msg)
-				cmds = append(cmds, cmd)
-				return m, tea.Batch(cmds...)
+				return HandleNewCommandModeKey(m, msg)
 			}
+			// Pass other keys to textarea in new command mode
+			m.textarea, cmd = m.textarea.Update(msg)
+			cmds = append(cmds, cmd)
+			return m, tea.Batch(cmds...)
+
+		case PaletteMode:
+			k := m.keys.Palette
+			if key.Matches(msg, k.Go, k.Run, k.Copy, k.Close) {
+				return HandlePaletteModeKey(m, msg)
+			}
+			m.palette, cmd = m.palette.Update(msg)
+			cmds = append(cmds, cmd)
+			return m, tea.Batch(cmds...)
+
+		case RevisionsMode:
+			return HandleRevisionsModeKey(m, msg)
+
+		case ConfirmMode:
+			return HandleConfirmModeKey(m, msg)
+
+		case HelpMode:
+			return HandleHelpModeKey(m, msg)
+
+		case PagerMode:
+			// Closing first leaves the pager search, then the pager
+			if key.Matches(msg, m.keys.Pager.Close) && !m.pager.Searching() {
+				m.currentMode = ViewMode
+				return m, nil
+			}
+			m.pager, cmd = m.pager.Update(msg)
+			return m, cmd
+
+		case SearchMode, TagMode, FilterMode, AssertMode, NormalizeMode:
+			k := m.keys.Prompt
+			if key.Matches(msg, k.Submit, k.Cancel) {
+				return HandlePromptModeKey(m, msg)
+			}
+			m.prompt, cmd = m.prompt.Update(msg)
+			cmds = append(cmds, cmd)
+			return m, tea.Batch(cmds...)
 		}
 
+	case tea.MouseMsg:
+		return HandleMouse(m, msg)
+
 	case execStartMsg:
 		// Update command status to running
 		for i, cmd := range m.cmds {
 			if cmd.ID == msg.cmdID {
 				m.cmds[i].Status = store.StatusRunning
 				m.cmds[i].ReturnCode = 0
+				m.cmds[i].StatusDetail = ""
 				m.store.UpdateCommandStatus(msg.cmdID, store.StatusRunning, 0)
 				m.cmdsHistory.SetCommands(m.cmds)
 				break
 			}
 		}
-	
+
 	case execCompleteMsg:
-		// Update command status based on exit code
-		status := store.StatusSuccess
-		if msg.exitCode != 0 {
-			status = store.StatusFailed
-		}
-		
+		// Update command status based on exit code and assertions
+		run, status, detail := recordRun(m.store, msg.cmdID, msg.result)
+
 		for i, cmd := range m.cmds {
 			if cmd.ID == msg.cmdID {
 				m.cmds[i].Status = status
-				m.cmds[i].ReturnCode = msg.exitCode
-				m.store.UpdateCommandStatus(msg.cmdID, status, msg.exitCode)
+				m.cmds[i].ReturnCode = run.ExitCode
+				m.cmds[i].StatusDetail = detail
 				m.cmdsHistory.SetCommands(m.cmds)
 				break
 			}
 		}
-	
+
+		m.cmdsHistory.SetOutput(run)
+		m.palette.SetLastRun(notebookPath(m.store), run)
+		m, cmd = refreshOutputDiff(m, msg.cmdID)
+		cmds = append(cmds, cmd, notifyCurrent(m, run))
+
+		// Go on with the next cell when running every cell
+		if m.runAll != nil && m.runAll.current == msg.cmdID {
+			m, cmd = cellRan(m, status)
+			cmds = append(cmds, cmd)
+		}
+
+	case clipboardMsg:
+		m, cmd = writeEscape(m, msg.escape)
+		cmds = append(cmds, cmd)
+		m, cmd = showFlash(m, "Copied "+msg.what+" to the clipboard")
+		cmds = append(cmds, cmd)
+
+	case editorFinishedMsg:
+		return saveEditedCommand(m, msg)
+
+	case flashExpiredMsg:
+		if msg.id == m.flashID {
+			m.flash = ""
+		}
+
+	case paletteRunMsg:
+		m.palette.SetLastRun(msg.notebook, msg.run)
+		cfg := m.config.For(store.NotebookName(msg.notebook))
+		return m, notifyIfLong(cfg, store.NotebookName(msg.notebook), msg.cell, msg.run)
+
+	case notifyMsg:
+		return showNotification(m, msg)
+
+	case escapeWrittenMsg:
+		if msg.id == m.escapeID {
+			m.escapeSequence = ""
+		}
+
 	default:
 		// Pass non-keyboard messages to components
 		m.textarea, cmd = m.textarea.Update(msg)
@@ -183,11 +353,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-func HandleViewModeKey(m Model, key string) (Model, tea.Cmd) {
-	switch key {
+func HandleViewModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := m.keys.View
+
+	switch {
 
 	// Create a new command
-	case "n":
+	case key.Matches(msg, k.New):
 		m.currentMode = NewCommandMode
 		m.currentIdx = -1
 		m.currentCmd = store.Command{}
@@ -197,51 +369,193 @@ func HandleViewModeKey(m Model, key string) (Model, tea.Cmd) {
 		m.cmdsHistory.SetHeight(m.height, true)
 
 	// Go one command up
-	case "up", "k":
-		if len(m.cmds) > 0 {
-			if m.currentIdx == -1 {
-				m.currentIdx = len(m.cmds) - 1
-			} else if m.currentIdx > 0 {
-				m.currentIdx -= 1
-			}
-			m.cmdsHistory.Select(m.currentIdx)
-		}
+	case key.Matches(msg, k.Up):
+		m, _ = moveSelection(m, -1)
 
 	// Go one command down
-	case "down", "j":
-		if len(m.cmds) > 0 {
-			if m.currentIdx == -1 {
-				m.currentIdx = 0
-			} else if m.currentIdx < len(m.cmds)-1 {
-				m.currentIdx += 1
-			}
-			m.cmdsHistory.Select(m.currentIdx)
-		}
+	case key.Matches(msg, k.Down):
+		m, _ = moveSelection(m, 1)
 
 	// Edit the current command inline
-	case "enter":
+	case key.Matches(msg, k.Edit):
 		if m.currentIdx < 0 {
 			return m, nil
 		}
 		m.currentMode = EditMode
 		m.cmdsHistory.StartInlineEdit(m.currentIdx)
+
+	// Search commands and outputs
+	case key.Matches(msg, k.Search):
+		return openPrompt(m, SearchMode, "/", "")
+
+	// Edit the tags of the current command
+	case key.Matches(msg, k.Tags):
+		if m.currentIdx < 0 {
+			return m, nil
+		}
+		tags := []string{}
+		for _, tag := range m.cmds[m.currentIdx].Tags {
+			tags = append(tags, "#"+tag)
+		}
+		return openPrompt(m, TagMode, "Tags: ", strings.Join(tags, " "))
+
+	// Edit the assertions checked after the current command runs
+	case key.Matches(msg, k.Assert):
+		if m.currentIdx < 0 {
+			return m, nil
+		}
+		assertions, err := m.store.GetAssertions(m.cmds[m.currentIdx].ID)
+		if err != nil {
+			return showError(m, "Failed to get assertions", err)
+		}
+		return openPrompt(m, AssertMode, "Assert: ", assert.Format(assertions))
+
+	// Edit the normalizers applied before comparing with the snapshot
+	case key.Matches(msg, k.Normalize):
+		if m.currentIdx < 0 {
+			return m, nil
+		}
+		normalizers, err := m.store.GetNormalizers(m.cmds[m.currentIdx].ID)
+		if err != nil {
+			return showError(m, "Failed to get normalizers", err)
+		}
+		return openPrompt(m, NormalizeMode, "Normalize: ", snapshot.Format(normalizers))
+
+	// Filter commands by tag and status
+	case key.Matches(msg, k.Filter):
+		return openPrompt(m, FilterMode, "Filter: ", m.cmdsHistory.Filter().String())
+
+	// Expand or collapse the output of the current command
+	case key.Matches(msg, k.Fold):
+		m.cmdsHistory.ToggleExpanded(m.currentIdx)
+
+	// Show both output streams, stdout only or stderr only
+	case key.Matches(msg, k.Stream):
+		m.cmdsHistory.CycleStream()
+
+	// Turn the shell checks on or off
+	case key.Matches(msg, k.Lint):
+		return toggleLint(m)
+
+	// Edit the current command in $EDITOR
+	case key.Matches(msg, k.External):
+		return openInEditor(m)
+
+	// Copy the command, its output or both as Markdown
+	case key.Matches(msg, k.CopyCommand):
+		return copySelected(m, "command")
+	case key.Matches(msg, k.CopyOutput):
+		return copySelected(m, "output")
+	case key.Matches(msg, k.CopyMarkdown):
+		return copySelected(m, "Markdown snippet")
+
+	// Run every cell one after the other
+	case key.Matches(msg, k.RunAll):
+		return startRunAll(m)
+
+	// Show or hide the inspector next to the cells
+	case key.Matches(msg, k.Split):
+		return toggleSplit(m)
+
+	// List the key bindings
+	case key.Matches(msg, k.Help):
+		return openHelp(m), nil
+
+	// Page through the output of the current command
+	case key.Matches(msg, k.Pager):
+		return openPager(m), nil
+
+	// Browse the revisions of the current command
+	case key.Matches(msg, k.Revisions):
+		return openRevisions(m)
+
+	// Show the output of the latest run compared to a previous one
+	case key.Matches(msg, k.Diff):
+		return toggleOutputDiff(m)
+
+	// Compare with an older run
+	case key.Matches(msg, k.OlderRun):
+		return stepOutputDiff(m, 1), nil
+
+	// Compare with a newer run
+	case key.Matches(msg, k.NewerRun):
+		return stepOutputDiff(m, -1), nil
+
+	// Pin the latest run as the golden one
+	case key.Matches(msg, k.Golden):
+		return toggleGoldenRun(m)
+
+	// Delete the current command
+	case key.Matches(msg, k.Delete):
+		return deleteCommand(m)
+
+	// Move the current command up
+	case key.Matches(msg, k.MoveUp):
+		return moveCommand(m, -1)
+
+	// Move the current command down
+	case key.Matches(msg, k.MoveDown):
+		return moveCommand(m, 1)
+
+	// Undo the last edit, insertion, deletion or move
+	case key.Matches(msg, k.Undo):
+		return replayOperation(m, true)
+
+	// Redo the last undone operation
+	case key.Matches(msg, k.Redo):
+		return replayOperation(m, false)
+
+	// Open the command palette
+	case key.Matches(msg, k.Palette):
+		return openPalette(m)
+
+	// Jump to the next search match
+	case key.Matches(msg, k.NextMatch):
+		return nextSearchMatch(m, 1), nil
+
+	// Jump to the previous search match
+	case key.Matches(msg, k.PrevMatch):
+		return nextSearchMatch(m, -1), nil
+
+	// Clear the search highlighting and the filter
+	case key.Matches(msg, k.Clear):
+		m = clearSearch(m)
+		return applyFilter(m, store.Filter{}), nil
 	}
 
 	return m, nil
 }
 
-func HandleEditModeKey(m Model, key string) (Model, tea.Cmd) {
-	switch key {
+// openPager shows the output of the current command in the pager
+func openPager(m Model) Model {
+	output, ok := m.cmdsHistory.Output(m.currentIdx)
+	if !ok {
+		return m
+	}
+	title := fmt.Sprintf("Output of cell %d", m.currentIdx+1)
+	if stream := m.cmdsHistory.Stream(); stream != "" {
+		title += " (" + stream + ")"
+	}
+	m.pager = pager.New(title, output, m.width, m.height-4, m.keys.Pager)
+	m.pager.MarkLines(m.cmdsHistory.StderrLines(m.currentIdx))
+	m.currentMode = PagerMode
+	return m
+}
+
+func HandleEditModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := m.keys.Edit
+
+	switch {
 	// Save the inline edited command without running it
-	case "ctrl+s":
+	case key.Matches(msg, k.Save):
 		return saveCommand(m), nil
 
 	// Save the inline edited command and run it
-	case "ctrl+r":
+	case key.Matches(msg, k.Run):
 		return saveAndRunCommand(m)
 
 	// Cancel inline editing and return to view mode
-	case "esc":
+	case key.Matches(msg, k.Cancel):
 		m.cmdsHistory.StopInlineEdit()
 		m.currentMode = ViewMode
 	}
@@ -249,14 +563,16 @@ func HandleEditModeKey(m Model, key string) (Model, tea.Cmd) {
 	return m, nil
 }
 
-func HandleNewCommandModeKey(m Model, key string) (Model, tea.Cmd) {
-	switch key {
+func HandleNewCommandModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := m.keys.NewCommand
+
+	switch {
 	// Register a new command and run it
-	case "ctrl+r":
+	case key.Matches(msg, k.Run):
 		return saveAndRunCommand(m)
 
 	// Cancel and return to view mode
-	case "esc":
+	case key.Matches(msg, k.Cancel):
 		m.currentMode = ViewMode
 		m.cmdsHistory.SetHeight(m.height, false)
 	}
@@ -264,6 +580,25 @@ func HandleNewCommandModeKey(m Model, key string) (Model, tea.Cmd) {
 	return m, nil
 }
 
+// Select the closest visible command in the given direction, returning
+// whether there was one
+func moveSelection(m Model, direction int) (Model, bool) {
+	idx := m.currentIdx
+	if idx == -1 && direction < 0 {
+		idx = len(m.cmds)
+	}
+
+	for idx += direction; idx >= 0 && idx < len(m.cmds); idx += direction {
+		if m.cmdsHistory.IsVisible(idx) {
+			m.currentIdx = idx
+			m.cmdsHistory.Select(idx)
+			return m, true
+		}
+	}
+
+	return m, false
+}
+
 // Save the command to the database and switch to viewMode
 func saveCommand(m Model) Model {
 	var command string
@@ -329,36 +664,59 @@ func saveCommand(m Model) Model {
 }
 
 // Execute command and return a tea.Cmd that will run it asynchronously
-func executeCommand(cmdID int64, command string) tea.Cmd {
+func executeCommand(cmdID int64, command string, opts executor.Options) tea.Cmd {
 	return func() tea.Msg {
-		result := executor.ExecuteCommand(command)
 		return execCompleteMsg{
-			cmdID:    cmdID,
-			exitCode: result.ExitCode,
+			cmdID:  cmdID,
+			result: executor.ExecuteCommand(command, opts),
 		}
 	}
 }
 
 // Save and run the command
 func saveAndRunCommand(m Model) (Model, tea.Cmd) {
+	command := m.textarea.Value()
+	if m.currentMode == EditMode {
+		command = m.cmdsHistory.GetEditedCommand()
+	}
+	if issue, found := checkBeforeRun(m.store, command); found {
+		return notRun(m, issue)
+	}
+
 	m = saveCommand(m)
-	
-	// Find the command that was just saved/updated and execute it
-	if m.currentMode == ViewMode && m.currentIdx >= 0 && m.currentIdx < len(m.cmds) {
-		cmd := m.cmds[m.currentIdx]
-		// Update command status to running
-		for i, c := range m.cmds {
-			if c.ID == cmd.ID {
-				m.cmds[i].Status = store.StatusRunning
-				m.cmds[i].ReturnCode = 0
-				m.store.UpdateCommandStatus(cmd.ID, store.StatusRunning, 0)
-				m.cmdsHistory.SetCommands(m.cmds)
-				break
-			}
-		}
-		// Return the async command execution
-		return m, executeCommand(cmd.ID, cmd.Command)
+
+	// Execute the command that was just saved/updated
+	if m.currentMode == ViewMode {
+		return runCommand(m, m.currentIdx)
 	}
-	
+
 	return m, nil
 }
+
+// Run the command at idx, once confirmed if the guard rules ask for it
+func runCommand(m Model, idx int) (Model, tea.Cmd) {
+	if idx < 0 || idx >= len(m.cmds) {
+		return m, nil
+	}
+
+	if issue, found := checkBeforeRun(m.store, m.cmds[idx].Command); found {
+		return notRun(m, issue)
+	}
+	if reasons := guardReasons(m.store, m.cmds[idx]); len(reasons) > 0 {
+		return askConfirmation(m, notebookPath(m.store), m.cmds[idx], reasons), nil
+	}
+	return startRun(m, idx)
+}
+
+// Mark the command at idx as running and execute it
+func startRun(m Model, idx int) (Model, tea.Cmd) {
+	cmd := m.cmds[idx]
+	m.cmds[idx].Status = store.StatusRunning
+	m.cmds[idx].ReturnCode = 0
+	m.cmds[idx].StatusDetail = ""
+	m.store.UpdateCommandStatus(cmd.ID, store.StatusRunning, 0)
+	m.cmdsHistory.SetCommands(m.cmds)
+
+	// Return the async command execution
+	return m, executeCommand(cmd.ID, cmd.Command, execOptions(m.settings()))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// historyTop is the row where the cells start, below the app name
+	historyTop = 2
+
+	// doubleClick is the longest delay between the two clicks of a double click
+	doubleClick = 400 * time.Millisecond
+
+	// wheelLines is how many lines a turn of the wheel scrolls
+	wheelLines = 3
+)
+
+// click is a press of the left button on a cell
+type click struct {
+	index int
+	x, y  int
+	at    time.Time
+}
+
+// HandleMouse selects a cell on click, edits it on double click and opens its
+// output on a click on its status. The wheel scrolls the cells or the pager.
+func HandleMouse(m Model, msg tea.MouseMsg) (Model, tea.Cmd) {
+	switch m.currentMode {
+	case PagerMode:
+		var cmd tea.Cmd
+		m.pager, cmd = m.pager.Update(msg)
+		return m, cmd
+
+	case HelpMode:
+		var cmd tea.Cmd
+		m.help.viewport, cmd = m.help.viewport.Update(msg)
+		return m, cmd
+
+	case ViewMode:
+	default:
+		return m, nil
+	}
+
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.cmdsHistory.Scroll(-wheelLines)
+		return m, nil
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.cmdsHistory.Scroll(wheelLines)
+		return m, nil
+	case msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionPress:
+		return m, nil
+	}
+
+	idx, onStatus := m.cmdsHistory.CellAt(msg.X, msg.Y-historyTop)
+	if idx < 0 {
+		return m, nil
+	}
+	previous := m.lastClick
+	m.lastClick = click{index: idx, x: msg.X, y: msg.Y, at: time.Now()}
+
+	m.currentIdx = idx
+	m.cmdsHistory.SelectInPlace(idx)
+
+	switch {
+	case onStatus:
+		return openPager(m), nil
+
+	case previous.index == idx && previous.x == msg.X && previous.y == msg.Y && time.Since(previous.at) < doubleClick:
+		m.lastClick = click{}
+		m.currentMode = EditMode
+		m.cmdsHistory.StartInlineEdit(m.currentIdx)
+	}
+	return m, nil
+}
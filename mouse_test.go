@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"cahier/config"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDoubleClickScrolledList(t *testing.T) {
+	commands := make([]string, 30)
+	for i := range commands {
+		commands[i] = fmt.Sprintf("echo %d", i+1)
+	}
+	db := newTestNotebook(t, filepath.Join(t.TempDir(), "cells.db"), commands...)
+
+	model, _ := NewModel(db, config.Default()).Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	m := model.(Model)
+
+	press := tea.MouseMsg{X: 10, Y: 4, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress}
+	m, _ = HandleMouse(m, press)
+	first := m.currentIdx
+	if first < 0 || first == len(commands)-1 {
+		t.Fatalf("selected cell %d, want one of the cells above the last one", first)
+	}
+
+	m, _ = HandleMouse(m, press)
+	if m.currentIdx != first {
+		t.Errorf("second click selected cell %d, want %d", m.currentIdx, first)
+	}
+	if m.currentMode != EditMode {
+		t.Errorf("mode %v after a double click, want EditMode", m.currentMode)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"cahier/config"
+	"cahier/notify"
+	"cahier/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+// escapeDuration keeps an escape in the view long enough for the renderer to
+// write it, which it does once as the line never changes
+const escapeDuration = time.Second
+
+// notifyMsg carries the escape to write to the terminal, or why notifying failed
+type notifyMsg struct {
+	escape string
+	err    error
+}
+
+// escapeWrittenMsg removes the escape from the view
+type escapeWrittenMsg struct {
+	id int
+}
+
+// longRun reports whether a run took at least as long as the notify setting
+// of its notebook
+func longRun(cfg config.Config, run store.Run) bool {
+	return cfg.Notify.After.Duration > 0 && run.Duration >= cfg.Notify.After.Duration
+}
+
+// sendNotification runs the notify command, or returns the escape notifying
+// through the terminal
+func sendNotification(cfg config.Config, message notify.Message) (string, error) {
+	if cfg.Notify.Method == notify.Command {
+		return "", notify.Run(cfg.Shell, cfg.Notify.Command, message)
+	}
+	return notify.Escape(cfg.Notify.Method, message), nil
+}
+
+// notifyIfLong tells that a cell of a notebook finished when it ran long.
+// The escapes go out with the view, so that they never interleave with it.
+func notifyIfLong(cfg config.Config, notebook string, cell int, run store.Run) tea.Cmd {
+	if !longRun(cfg, run) {
+		return nil
+	}
+
+	message := notify.Message{Notebook: notebook, Cell: cell, ExitCode: run.ExitCode, Duration: run.Duration}
+	return func() tea.Msg {
+		escape, err := sendNotification(cfg, message)
+		return notifyMsg{escape: escape, err: err}
+	}
+}
+
+// notifyCurrent tells that a cell of the current notebook finished when it ran long
+func notifyCurrent(m Model, run store.Run) tea.Cmd {
+	cell := slices.IndexFunc(m.cmds, func(cmd store.Command) bool { return cmd.ID == run.CommandID }) + 1
+	return notifyIfLong(m.settings(), m.store.Name(), cell, run)
+}
+
+// showNotification puts the escape of a notification in the view until it was written
+func showNotification(m Model, msg notifyMsg) (Model, tea.Cmd) {
+	if msg.err != nil {
+		return showFlash(m, errorStyle.Render("Notify failed: "+msg.err.Error()))
+	}
+	return writeEscape(m, msg.escape)
+}
+
+// writeEscape puts an escape in the view until it was written. Escapes for the
+// terminal itself, like notifications and OSC52 copies, all go out this way so
+// that they never interleave with the frames of the renderer.
+func writeEscape(m Model, escape string) (Model, tea.Cmd) {
+	if escape == "" {
+		return m, nil
+	}
+
+	m.escapeID++
+	m.escapeSequence = escape
+	id := m.escapeID
+	return m, tea.Tick(escapeDuration, func(time.Time) tea.Msg {
+		return escapeWrittenMsg{id: id}
+	})
+}
+
+// notifyLongCLI tells that a cell run from the command line finished when it
+// ran long, writing the escapes only when w is a terminal
+func notifyLongCLI(w io.Writer, cfg config.Config, notebook string, cell int, run store.Run) {
+	if !longRun(cfg, run) {
+		return
+	}
+
+	message := notify.Message{Notebook: notebook, Cell: cell, ExitCode: run.ExitCode, Duration: run.Duration}
+	escape, err := sendNotification(cfg, message)
+	if err != nil {
+		log.Printf("Failed to notify: %v", err)
+		return
+	}
+	if f, ok := w.(*os.File); ok && escape != "" && term.IsTerminal(f.Fd()) {
+		io.WriteString(f, escape)
+	}
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ways to notify that a cell finished
+const (
+	Bell    = "bell"    // Terminal bell, which most terminals turn into an alert of their window
+	OSC9    = "osc9"    // Desktop notification escape of iTerm2, WezTerm, Windows Terminal and others
+	OSC777  = "osc777"  // Desktop notification escape of rxvt, foot, Ghostty and others
+	Command = "command" // Hook command such as notify-send
+)
+
+// hookTimeout stops hook commands that hang
+const hookTimeout = 10 * time.Second
+
+// Methods returns the ways to notify
+func Methods() []string {
+	return []string{Bell, OSC9, OSC777, Command}
+}
+
+// Message describes a cell that took long to finish
+type Message struct {
+	Notebook string
+	Cell     int // Shown number, starting at 1
+	ExitCode int
+	Duration time.Duration
+}
+
+func (m Message) Title() string {
+	return "cahier: " + m.Notebook
+}
+
+func (m Message) Body() string {
+	outcome := "finished"
+	if m.ExitCode != 0 {
+		outcome = "failed"
+	}
+	return fmt.Sprintf("Cell %d %s with exit code %d after %s", m.Cell, outcome, m.ExitCode, m.Duration.Round(time.Second))
+}
+
+// Escape returns what to write to the terminal to notify by the bell or an
+// OSC escape, or an empty string for the other methods
+func Escape(method string, m Message) string {
+	switch method {
+	case Bell:
+		return "\a"
+	case OSC9:
+		return "\x1b]9;" + printable(m.Title()+": "+m.Body()) + "\a"
+	case OSC777:
+		return "\x1b]777;notify;" + printable(strings.ReplaceAll(m.Title(), ";", ",")) + ";" + printable(m.Body()) + "\a"
+	}
+	return ""
+}
+
+// printable removes the control characters that would end an escape early
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Run executes a hook command with the shell, giving it the message in
+// environment variables: CAHIER_TITLE, CAHIER_MESSAGE, CAHIER_NOTEBOOK,
+// CAHIER_CELL, CAHIER_EXIT_CODE and CAHIER_DURATION in seconds
+func Run(shell, command string, m Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, shell, "-c", command)
+	cmd.Env = append(os.Environ(),
+		"CAHIER_TITLE="+m.Title(),
+		"CAHIER_MESSAGE="+m.Body(),
+		"CAHIER_NOTEBOOK="+m.Notebook,
+		"CAHIER_CELL="+strconv.Itoa(m.Cell),
+		"CAHIER_EXIT_CODE="+strconv.Itoa(m.ExitCode),
+		"CAHIER_DURATION="+strconv.FormatFloat(m.Duration.Seconds(), 'f', 0, 64),
+	)
+
+	// The output would garble the interface, it is only kept for the error
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
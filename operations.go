@@ -0,0 +1,79 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Delete the current command
+func deleteCommand(m Model) (Model, tea.Cmd) {
+	if m.currentIdx < 0 {
+		return m, nil
+	}
+
+	if err := m.store.DeleteCommand(m.cmds[m.currentIdx].ID); err != nil {
+		return showError(m, "Failed to delete command", err)
+	}
+
+	return reloadAndSelect(m, m.currentIdx)
+}
+
+// Swap the current command with its neighbour in the given direction
+func moveCommand(m Model, direction int) (Model, tea.Cmd) {
+	if m.currentIdx < 0 {
+		return m, nil
+	}
+
+	moved, err := m.store.MoveCommand(m.cmds[m.currentIdx].ID, direction)
+	if err != nil {
+		return showError(m, "Failed to move command", err)
+	}
+	if !moved {
+		return m, nil
+	}
+
+	return reloadAndSelect(m, m.currentIdx+direction)
+}
+
+// Undo or redo the last operation and select the command it touched
+func replayOperation(m Model, undo bool) (Model, tea.Cmd) {
+	replay := m.store.Redo
+	if undo {
+		replay = m.store.Undo
+	}
+
+	op, ok, err := replay()
+	if err != nil {
+		return showError(m, "Failed to replay operation", err)
+	}
+	if !ok {
+		return m, nil
+	}
+
+	m, cmd := reloadAndSelect(m, m.currentIdx)
+	for i, c := range m.cmds {
+		if c.ID == op.CommandID {
+			m.currentIdx = i
+			m.cmdsHistory.Select(i)
+			break
+		}
+	}
+
+	return m, cmd
+}
+
+// Reload the commands after the notebook changed and select the command at
+// idx, or the closest one still in the notebook. The command it returns
+// flashes the error when reloading failed.
+func reloadAndSelect(m Model, idx int) (Model, tea.Cmd) {
+	if err := reloadCommands(&m); err != nil {
+		return showError(m, "Failed to get commands", err)
+	}
+
+	m.currentIdx = min(idx, len(m.cmds)-1)
+	if m.currentIdx < 0 {
+		m.cmdsHistory.ClearSelection()
+		return m, nil
+	}
+	m.cmdsHistory.Select(m.currentIdx)
+	return m, nil
+}
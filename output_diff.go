@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+
+	"cahier/diff"
+	"cahier/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// outputDiff compares the output of the latest run of a command with a
+// previous run or with its golden run
+type outputDiff struct {
+	cmdID   int64
+	runs    []store.Run // Most recent first
+	golden  int64       // ID of the pinned golden run, zero if none
+	against int         // Index in runs of the compared run, -1 for the golden run
+}
+
+func loadOutputDiff(db *store.Store, cmdID int64) (outputDiff, error) {
+	runs, err := db.GetRuns(cmdID)
+	if err != nil {
+		return outputDiff{}, err
+	}
+	golden, err := db.GetGoldenRunID(cmdID)
+	if err != nil {
+		return outputDiff{}, err
+	}
+
+	// Compare with the golden run when there is an older one, else with the previous run
+	d := outputDiff{cmdID: cmdID, runs: runs, golden: golden, against: 1}
+	if run, ok := d.goldenRun(); ok && run.ID != runs[0].ID {
+		d.against = -1
+	}
+	return d, nil
+}
+
+func (d outputDiff) goldenRun() (store.Run, bool) {
+	if d.golden == 0 {
+		return store.Run{}, false
+	}
+	for _, run := range d.runs {
+		if run.ID == d.golden {
+			return run, true
+		}
+	}
+	return store.Run{}, false
+}
+
+// pair returns the compared run and the latest run
+func (d outputDiff) pair() (store.Run, store.Run, bool) {
+	if len(d.runs) == 0 {
+		return store.Run{}, store.Run{}, false
+	}
+	if d.against == -1 {
+		golden, ok := d.goldenRun()
+		return golden, d.runs[0], ok
+	}
+	if d.against >= len(d.runs) {
+		return store.Run{}, d.runs[0], false
+	}
+	return d.runs[d.against], d.runs[0], true
+}
+
+// step moves the compared run towards older (direction > 0) or newer runs
+func (d outputDiff) step(direction int) outputDiff {
+	d.against = min(max(d.against+direction, 1), max(len(d.runs)-1, 1))
+	return d
+}
+
+func (d outputDiff) lines() []diff.Line {
+	old, latest, _ := d.pair()
+	return diff.Lines(old.Output, latest.Output)
+}
+
+func describeRun(run store.Run) string {
+	return fmt.Sprintf("%s, exit %d", run.StartedAt.Format("2006-01-02 15:04:05"), run.ExitCode)
+}
+
+// header describes the compared runs
+func (d outputDiff) header() string {
+	old, latest, ok := d.pair()
+	switch {
+	case len(d.runs) == 0:
+		return "Never run"
+	case !ok:
+		return "Only one run, nothing to compare with"
+	case d.against == -1:
+		return fmt.Sprintf("Latest run (%s) against golden run (%s)", describeRun(latest), describeRun(old))
+	case old.ID == d.golden:
+		return fmt.Sprintf("Latest run (%s) against golden run %d (%s)", describeRun(latest), d.against+1, describeRun(old))
+	default:
+		return fmt.Sprintf("Latest run (%s) against run %d (%s)", describeRun(latest), d.against+1, describeRun(old))
+	}
+}
+
+// render returns the colored diff shown in the history pane
+func (d outputDiff) render() string {
+	header := faintStyle.Render(d.header())
+	if _, _, ok := d.pair(); !ok {
+		return header
+	}
+
+	lines := d.lines()
+	if !diff.Changed(lines) {
+		return header + "\n" + faintStyle.Render("Same output")
+	}
+	return header + "\n" + diff.Render(lines, 3)
+}
+
+// Show or hide the output diff of the current command
+func toggleOutputDiff(m Model) (Model, tea.Cmd) {
+	if m.outputDiff != nil {
+		return hideOutputDiff(m), nil
+	}
+	if m.currentIdx < 0 {
+		return m, nil
+	}
+
+	d, err := loadOutputDiff(m.store, m.cmds[m.currentIdx].ID)
+	if err != nil {
+		return showError(m, "Failed to load runs", err)
+	}
+	return showOutputDiff(m, d), nil
+}
+
+func showOutputDiff(m Model, d outputDiff) Model {
+	m.outputDiff = &d
+	m.cmdsHistory.SetDetail(d.cmdID, d.render())
+	return m
+}
+
+func hideOutputDiff(m Model) Model {
+	m.outputDiff = nil
+	m.cmdsHistory.SetDetail(0, "")
+	return m
+}
+
+// Compare the latest output with an older or newer run
+func stepOutputDiff(m Model, direction int) Model {
+	if m.outputDiff == nil {
+		return m
+	}
+	return showOutputDiff(m, m.outputDiff.step(direction))
+}
+
+// Pin the latest run of the diffed command as its golden run, or unpin it
+func toggleGoldenRun(m Model) (Model, tea.Cmd) {
+	if m.outputDiff == nil || len(m.outputDiff.runs) == 0 {
+		return m, nil
+	}
+
+	d := *m.outputDiff
+	golden := d.runs[0].ID
+	if d.golden == golden {
+		golden = 0
+	}
+	if err := m.store.SetGoldenRun(d.cmdID, golden); err != nil {
+		return showError(m, "Failed to pin golden run", err)
+	}
+
+	d, err := loadOutputDiff(m.store, d.cmdID)
+	if err != nil {
+		return showError(m, "Failed to load runs", err)
+	}
+	return showOutputDiff(m, d), nil
+}
+
+// Refresh the output diff after the diffed command ran again
+func refreshOutputDiff(m Model, cmdID int64) (Model, tea.Cmd) {
+	if m.outputDiff == nil || m.outputDiff.cmdID != cmdID {
+		return m, nil
+	}
+
+	d, err := loadOutputDiff(m.store, cmdID)
+	if err != nil {
+		return showError(m, "Failed to load runs", err)
+	}
+	return showOutputDiff(m, d), nil
+}
@@ -0,0 +1,219 @@
+package pager
+
+import (
+	"fmt"
+	"strings"
+
+	"cahier/keymap"
+	"cahier/theme"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle        = lipgloss.NewStyle().Bold(true)
+	matchStyle        lipgloss.Style
+	currentMatchStyle lipgloss.Style
+	markedStyle       lipgloss.Style
+
+	faintStyle = lipgloss.NewStyle().Faint(true)
+)
+
+// SetTheme colors the title, the search matches and the marked lines
+func SetTheme(t theme.Theme) {
+	titleStyle = titleStyle.Foreground(lipgloss.Color(t.Accent))
+	matchStyle = t.Marked(t.Highlight)
+	currentMatchStyle = t.Marked(t.Error).Bold(true)
+	markedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Error))
+}
+
+// Model is a scrollable view of a single output with its own search
+type Model struct {
+	title     string
+	keys      keymap.Pager
+	lines     []string
+	marked    []bool // Lines standing out, such as the ones written to stderr
+	viewport  viewport.Model
+	input     textinput.Model
+	searching bool
+	query     string
+	matches   []int // Indexes of the lines containing the query
+	current   int   // Index in matches of the focused match
+}
+
+func New(title, content string, width, height int, keys keymap.Pager) Model {
+	input := textinput.New()
+	input.Prompt = "/"
+
+	m := Model{
+		title:    title,
+		keys:     keys,
+		lines:    strings.Split(content, "\n"),
+		viewport: viewport.New(width, 0),
+		input:    input,
+	}
+	m.SetSize(width, height)
+	m.render()
+	return m
+}
+
+// SetSize resizes the pager, keeping a line for the title and one for the footer
+func (m *Model) SetSize(width, height int) {
+	m.viewport.Width = width
+	m.viewport.Height = max(height-2, 1)
+}
+
+// MarkLines makes the given lines stand out
+func (m *Model) MarkLines(marked []bool) {
+	m.marked = marked
+	m.render()
+}
+
+// Searching reports whether the search input has the focus
+func (m Model) Searching() bool {
+	return m.searching
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		if m.searching {
+			switch {
+			case msg.String() == "enter":
+				m.searching = false
+				m.input.Blur()
+				m.search(m.input.Value())
+				return m, nil
+			case key.Matches(msg, m.keys.Close):
+				m.searching = false
+				m.input.Blur()
+				return m, nil
+			}
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Search):
+			m.searching = true
+			m.input.SetValue(m.query)
+			m.input.CursorEnd()
+			return m, m.input.Focus()
+		case key.Matches(msg, m.keys.NextMatch):
+			m.jump(1)
+			return m, nil
+		case key.Matches(msg, m.keys.PrevMatch):
+			m.jump(-1)
+			return m, nil
+		case key.Matches(msg, m.keys.Top):
+			m.viewport.GotoTop()
+			return m, nil
+		case key.Matches(msg, m.keys.Bottom):
+			m.viewport.GotoBottom()
+			return m, nil
+		}
+	}
+
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+// search finds the lines containing the query, ignoring case, and shows the first one
+func (m *Model) search(query string) {
+	m.query = query
+	m.matches = nil
+	m.current = 0
+
+	if query != "" {
+		lower := strings.ToLower(query)
+		for i, line := range m.lines {
+			if strings.Contains(strings.ToLower(line), lower) {
+				m.matches = append(m.matches, i)
+			}
+		}
+	}
+
+	m.render()
+	m.showCurrent()
+}
+
+// jump focuses the next or previous match, wrapping around
+func (m *Model) jump(direction int) {
+	if len(m.matches) == 0 {
+		return
+	}
+	m.current = (m.current + direction + len(m.matches)) % len(m.matches)
+	m.render()
+	m.showCurrent()
+}
+
+func (m *Model) showCurrent() {
+	if len(m.matches) == 0 {
+		return
+	}
+	m.viewport.SetYOffset(max(m.matches[m.current]-m.viewport.Height/2, 0))
+}
+
+func (m *Model) render() {
+	lines := make([]string, len(m.lines))
+	copy(lines, m.lines)
+	for i := range lines {
+		if i < len(m.marked) && m.marked[i] {
+			lines[i] = markedStyle.Render(lines[i])
+		}
+	}
+
+	for i, idx := range m.matches {
+		style := matchStyle
+		if i == m.current {
+			style = currentMatchStyle
+		}
+		lines[idx] = highlight(m.lines[idx], m.query, style)
+	}
+	m.viewport.SetContent(strings.Join(lines, "\n"))
+}
+
+// highlight renders every case-insensitive occurrence of query in line
+func highlight(line, query string, style lipgloss.Style) string {
+	lower := strings.ToLower(line)
+	query = strings.ToLower(query)
+	if len(lower) != len(line) {
+		return line
+	}
+
+	var b strings.Builder
+	for {
+		idx := strings.Index(lower, query)
+		if idx < 0 {
+			break
+		}
+		b.WriteString(line[:idx] + style.Render(line[idx:idx+len(query)]))
+		line, lower = line[idx+len(query):], lower[idx+len(query):]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+func (m Model) View() string {
+	title := titleStyle.Render(m.title)
+
+	var footer string
+	switch {
+	case m.searching:
+		footer = m.input.View()
+	case m.query != "" && len(m.matches) == 0:
+		footer = faintStyle.Render(fmt.Sprintf("No match for %q", m.query))
+	case m.query != "":
+		footer = faintStyle.Render(fmt.Sprintf("Match %d/%d for %q - %s: Next - %s: Previous",
+			m.current+1, len(m.matches), m.query, m.keys.NextMatch.Help().Key, m.keys.PrevMatch.Help().Key))
+	default:
+		footer = faintStyle.Render(fmt.Sprintf("%d lines - %3.f%%", len(m.lines), m.viewport.ScrollPercent()*100))
+	}
+
+	return title + "\n" + m.viewport.View() + "\n" + footer
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"slices"
+
+	"cahier/executor"
+	"cahier/lint"
+	"cahier/palette"
+	"cahier/store"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type paletteRunMsg struct {
+	notebook string
+	cell     int // Number of the cell in its notebook, 0 when unknown
+	run      store.Run
+}
+
+// notebookPath returns the absolute path of a notebook, used to tell notebooks apart
+func notebookPath(db *store.Store) string {
+	path, err := filepath.Abs(db.Path())
+	if err != nil {
+		return db.Path()
+	}
+	return path
+}
+
+// withNotebook calls fn with the store of the notebook at path, opening it
+// unless it is the current one
+func withNotebook(m Model, path string, fn func(db *store.Store) error) error {
+	if path == notebookPath(m.store) {
+		return fn(m.store)
+	}
+
+	db := &store.Store{}
+	if err := db.Init(path); err != nil {
+		return err
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+// readNotebook calls fn with the store of the notebook at path, opened
+// read-only unless it is the current one, so that listing the other notebooks
+// never writes to or upgrades them
+func readNotebook(m Model, path string, fn func(db *store.Store) error) error {
+	if path == notebookPath(m.store) {
+		return fn(m.store)
+	}
+
+	db := &store.Store{}
+	if err := db.OpenReadOnly(path); err != nil {
+		return err
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+// loadPaletteItems collects the commands of every notebook next to the current one
+func loadPaletteItems(m Model) ([]palette.Item, error) {
+	current := notebookPath(m.store)
+	paths, err := store.Notebooks(filepath.Dir(current))
+	if err != nil {
+		return nil, err
+	}
+
+	// The current notebook comes last so that its commands are listed first
+	notebooks := []string{}
+	for _, path := range paths {
+		if path, err = filepath.Abs(path); err == nil && path != current {
+			notebooks = append(notebooks, path)
+		}
+	}
+	notebooks = append(notebooks, current)
+
+	items := []palette.Item{}
+	for _, path := range notebooks {
+		err := readNotebook(m, path, func(db *store.Store) error {
+			cmds, err := db.GetCommands()
+			if err != nil {
+				return err
+			}
+			lastRuns, err := db.GetLastRuns()
+			if err != nil {
+				return err
+			}
+
+			for i, cmd := range cmds {
+				item := palette.Item{Notebook: path, Index: i, Command: cmd}
+				if run, ok := lastRuns[cmd.ID]; ok {
+					item.LastRun = &run
+				}
+				items = append(items, item)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to load notebook %s: %v", path, err)
+		}
+	}
+
+	return items, nil
+}
+
+func openPalette(m Model) (Model, tea.Cmd) {
+	items, err := loadPaletteItems(m)
+	if err != nil {
+		return showError(m, "Failed to list notebooks", err)
+	}
+
+	m.palette = palette.New(items, m.width, m.keys.Palette)
+	m.currentMode = PaletteMode
+	return m, nil
+}
+
+func HandlePaletteModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := m.keys.Palette
+	item, ok := m.palette.Selected()
+
+	switch {
+	// Jump to the cell in its notebook
+	case key.Matches(msg, k.Go):
+		if !ok {
+			return m, nil
+		}
+		if item.Notebook != notebookPath(m.store) {
+			var err error
+			if m, err = switchNotebook(m, item.Notebook); err != nil {
+				return showError(m, "Failed to open notebook "+item.Notebook, err)
+			}
+		}
+		m.currentMode = ViewMode
+		m.currentIdx = item.Index
+		m.cmdsHistory.Select(m.currentIdx)
+
+		// The notebook may animate where the previous one did not
+		if !m.ticking && animates(m) {
+			m.ticking = true
+			return m, tickCmd(m.settings().Tick.Duration)
+		}
+
+	// Run the command in place, keeping the palette open to show the result
+	case key.Matches(msg, k.Run):
+		if !ok {
+			return m, nil
+		}
+		if item.Notebook == notebookPath(m.store) {
+			return runCommand(m, item.Index)
+		}
+
+		var reasons []string
+		var issue lint.Issue
+		var found bool
+		err := withNotebook(m, item.Notebook, func(db *store.Store) error {
+			issue, found = checkBeforeRun(db, item.Command.Command)
+			reasons = guardReasons(db, item.Command)
+			return nil
+		})
+		if err != nil {
+			return showError(m, "Failed to open notebook "+item.Notebook, err)
+		}
+		if found {
+			return notRun(m, issue)
+		}
+		if len(reasons) > 0 {
+			return askConfirmation(m, item.Notebook, item.Command, reasons), nil
+		}
+		return m, runInNotebook(item.Notebook, item.Command, execOptions(m.config.For(store.NotebookName(item.Notebook))))
+
+	// Copy the command into a new cell of the current notebook
+	case key.Matches(msg, k.Copy):
+		if !ok {
+			return m, nil
+		}
+		if err := m.store.SaveCommand(store.Command{Command: item.Command.Command}); err != nil {
+			return showError(m, "Failed to save command", err)
+		}
+		if err := reloadCommands(&m); err != nil {
+			return showError(m, "Failed to get commands", err)
+		}
+		m.currentMode = ViewMode
+		m.currentIdx = len(m.cmds) - 1
+		m.cmdsHistory.Select(m.currentIdx)
+
+	// Close the palette
+	case key.Matches(msg, k.Close):
+		m.currentMode = ViewMode
+	}
+
+	return m, nil
+}
+
+// runInNotebook executes a command from another notebook and records the run there
+func runInNotebook(path string, cmd store.Command, opts executor.Options) tea.Cmd {
+	return func() tea.Msg {
+		result := executor.ExecuteCommand(cmd.Command, opts)
+
+		db := &store.Store{}
+		if err := db.Init(path); err != nil {
+			log.Printf("Failed to open notebook %s: %v", path, err)
+			return paletteRunMsg{notebook: path, run: newRun(cmd.ID, result)}
+		}
+		defer db.Close()
+
+		run, _, _ := recordRun(db, cmd.ID, result)
+		cell := 0
+		if cmds, err := db.GetCommands(); err == nil {
+			cell = slices.IndexFunc(cmds, func(c store.Command) bool { return c.ID == cmd.ID }) + 1
+		}
+		return paletteRunMsg{notebook: path, cell: cell, run: run}
+	}
+}
+
+// switchNotebook makes the notebook at path the current one
+func switchNotebook(m Model, path string) (Model, error) {
+	db := &store.Store{}
+	if err := db.Init(path); err != nil {
+		return m, err
+	}
+
+	m.store.Close()
+	m.store = db
+	m.runAll = nil
+	m.lastRunAll = loadLastRunAll(db)
+	m.cmdsHistory.SetLint(lintEnabled(db))
+	m.cmdsHistory.SetFoldLines(m.settings().Output.FoldLines)
+	m, err := loadTheme(m)
+	if err != nil {
+		return m, err
+	}
+	m = clearSearch(m)
+	if err := reloadCommands(&m); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+// reloadCommands refreshes the commands from the current notebook
+func reloadCommands(m *Model) error {
+	cmds, err := m.store.GetCommands()
+	if err != nil {
+		return err
+	}
+	outputs, err := m.store.GetLastRuns()
+	if err != nil {
+		return err
+	}
+	m.cmds = cmds
+	m.cmdsHistory.SetCommands(m.cmds)
+	m.cmdsHistory.SetOutputs(outputs)
+	return nil
+}
@@ -0,0 +1,236 @@
+package palette
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cahier/keymap"
+	"cahier/store"
+	"cahier/theme"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+const (
+	maxResults      = 10
+	maxPreviewLines = 10
+)
+
+var (
+	notebookStyle     = lipgloss.NewStyle()
+	selectedItemStyle = lipgloss.NewStyle().Bold(true)
+	matchedCharStyle  = lipgloss.NewStyle().Underline(true)
+
+	previewStyle = lipgloss.NewStyle().
+			Padding(0, 1).
+			BorderStyle(lipgloss.RoundedBorder())
+
+	faintStyle = lipgloss.NewStyle().Faint(true)
+)
+
+// SetTheme colors the notebook names, the selected item and the preview
+func SetTheme(t theme.Theme) {
+	notebookStyle = notebookStyle.Foreground(lipgloss.Color(t.Accent))
+	selectedItemStyle = selectedItemStyle.Foreground(lipgloss.Color(t.Info))
+	matchedCharStyle = matchedCharStyle.Foreground(lipgloss.Color(t.Warning))
+	previewStyle = previewStyle.BorderForeground(lipgloss.Color(t.Border))
+}
+
+// Item is a command from one of the notebooks, with its last run if any
+type Item struct {
+	Notebook string // Path of the notebook database holding the command
+	Index    int    // Position of the command in its notebook
+	Command  store.Command
+	LastRun  *store.Run
+}
+
+type items []Item
+
+// Commands are matched on a single line so that multiline commands still
+// display correctly in the result list
+func (it items) String(i int) string { return oneLine(it[i].Command.Command) }
+func (it items) Len() int            { return len(it) }
+
+type Model struct {
+	keys     keymap.Palette
+	input    textinput.Model
+	items    items
+	matches  fuzzy.Matches
+	selected int
+	width    int
+}
+
+func New(list []Item, width int, keys keymap.Palette) Model {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "Search commands in all notebooks"
+	input.Focus()
+
+	m := Model{
+		keys:  keys,
+		input: input,
+		items: list,
+		width: width,
+	}
+	m.filter()
+	return m
+}
+
+// filter matches the items against the query. An empty query lists every
+// item, most recently added first.
+func (m *Model) filter() {
+	query := m.input.Value()
+	if query == "" {
+		m.matches = make(fuzzy.Matches, len(m.items))
+		for i := range m.items {
+			idx := len(m.items) - 1 - i
+			m.matches[i] = fuzzy.Match{Str: m.items.String(idx), Index: idx}
+		}
+	} else {
+		m.matches = fuzzy.FindFrom(query, m.items)
+	}
+	m.selected = 0
+}
+
+// Selected returns the highlighted item
+func (m Model) Selected() (Item, bool) {
+	if m.selected < 0 || m.selected >= len(m.matches) {
+		return Item{}, false
+	}
+	return m.items[m.matches[m.selected].Index], true
+}
+
+// SetLastRun updates the last run shown in the preview of a command
+func (m *Model) SetLastRun(notebook string, run store.Run) {
+	for i, item := range m.items {
+		if item.Notebook == notebook && item.Command.ID == run.CommandID {
+			m.items[i].LastRun = &run
+		}
+	}
+}
+
+func (m *Model) SetWidth(width int) {
+	m.width = width
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, m.keys.Up):
+			if m.selected > 0 {
+				m.selected--
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.Down):
+			if m.selected < len(m.matches)-1 {
+				m.selected++
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	previous := m.input.Value()
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() != previous {
+		m.filter()
+	}
+	return m, cmd
+}
+
+func (m Model) View() string {
+	var b strings.Builder
+	b.WriteString(m.input.View() + "\n\n")
+
+	if len(m.matches) == 0 {
+		b.WriteString(faintStyle.Render("No matching command"))
+		return b.String()
+	}
+
+	// Keep the selection within the visible window of results
+	start := max(m.selected-maxResults+1, 0)
+	end := min(start+maxResults, len(m.matches))
+	for i := start; i < end; i++ {
+		match := m.matches[i]
+		item := m.items[match.Index]
+
+		cursor := "  "
+		if i == m.selected {
+			cursor = selectedItemStyle.Render("> ")
+		}
+
+		origin := notebookStyle.Render(fmt.Sprintf("%s:%d", store.NotebookName(item.Notebook), item.Index+1))
+		line := truncate(match.Str, max(m.width-lipgloss.Width(origin)-4, 10))
+		b.WriteString(cursor + origin + " " + highlightMatches(line, match.MatchedIndexes, i == m.selected) + "\n")
+	}
+	if len(m.matches) > end {
+		b.WriteString(faintStyle.Render(fmt.Sprintf("  … %d more", len(m.matches)-end)) + "\n")
+	}
+
+	if item, ok := m.Selected(); ok {
+		b.WriteString("\n" + previewStyle.Width(max(m.width-2, 20)).Render(preview(item)))
+	}
+
+	return b.String()
+}
+
+// preview renders the full command and the tail of its last output
+func preview(item Item) string {
+	s := item.Command.Command + "\n\n"
+
+	if item.LastRun == nil {
+		return s + faintStyle.Render("Never run")
+	}
+
+	run := item.LastRun
+	s += faintStyle.Render(fmt.Sprintf("Last run %s ago, exit %d in %s",
+		time.Since(run.StartedAt).Round(time.Second), run.ExitCode, run.Duration.Round(time.Millisecond)))
+
+	output := run.Output
+	if output == "" {
+		return s
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) > maxPreviewLines {
+		lines = append([]string{"…"}, lines[len(lines)-maxPreviewLines:]...)
+	}
+	return s + "\n" + strings.Join(lines, "\n")
+}
+
+func highlightMatches(s string, indexes []int, selected bool) string {
+	base := lipgloss.NewStyle()
+	if selected {
+		base = selectedItemStyle
+	}
+
+	matched := make(map[int]bool, len(indexes))
+	for _, idx := range indexes {
+		matched[idx] = true
+	}
+
+	var b strings.Builder
+	for i, r := range s {
+		if matched[i] {
+			b.WriteString(matchedCharStyle.Render(string(r)))
+		} else {
+			b.WriteString(base.Render(string(r)))
+		}
+	}
+	return b.String()
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
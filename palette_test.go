@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cahier/config"
+	"cahier/keymap"
+	"cahier/palette"
+	"cahier/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func newTestNotebook(t *testing.T, path string, commands ...string) *store.Store {
+	t.Helper()
+	db := &store.Store{}
+	if err := db.Init(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, command := range commands {
+		if err := db.SaveCommand(store.Command{Command: command}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestPaletteRunChecksSyntax(t *testing.T) {
+	dir := t.TempDir()
+	current := newTestNotebook(t, filepath.Join(dir, "current.db"), "if true; then")
+	other := newTestNotebook(t, filepath.Join(dir, "other.db"), "echo 'unterminated")
+	otherCmds, err := other.GetCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentCmds, err := current.GetCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctrlR := tea.KeyMsg{Type: tea.KeyCtrlR}
+	for _, item := range []palette.Item{
+		{Notebook: notebookPath(current), Index: 0, Command: currentCmds[0]},
+		{Notebook: filepath.Join(dir, "other.db"), Index: 0, Command: otherCmds[0]},
+	} {
+		m := NewModel(current, config.Default())
+		m.palette = palette.New([]palette.Item{item}, 80, keymap.Default().Palette)
+		m.currentMode = PaletteMode
+
+		m, _ = HandlePaletteModeKey(m, ctrlR)
+		if !strings.Contains(m.flash, "Not run") {
+			t.Errorf("%s: flash %q, want the syntax error", store.NotebookName(item.Notebook), m.flash)
+		}
+		if m.cmds[0].Status == store.StatusRunning {
+			t.Errorf("%s: the cell started running", store.NotebookName(item.Notebook))
+		}
+	}
+
+	// Turning the checks off lets the command run, and fail, anyway
+	if err := other.SetSetting(lintSetting, "off"); err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(current, config.Default())
+	m.palette = palette.New([]palette.Item{{Notebook: filepath.Join(dir, "other.db"), Command: otherCmds[0]}}, 80, keymap.Default().Palette)
+	m.currentMode = PaletteMode
+	m, cmd := HandlePaletteModeKey(m, ctrlR)
+	if m.flash != "" || cmd == nil {
+		t.Errorf("flash %q, want the command to run with the checks off", m.flash)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+
+	"cahier/assert"
+	"cahier/snapshot"
+	"cahier/store"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Open the single line prompt for the given mode
+func openPrompt(m Model, mode Status, prompt string, value string) (Model, tea.Cmd) {
+	m.currentMode = mode
+	m.promptErr = ""
+	m.prompt.Prompt = prompt
+	m.prompt.SetValue(value)
+	m.prompt.CursorEnd()
+	return m, m.prompt.Focus()
+}
+
+func HandlePromptModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	if key.Matches(msg, m.keys.Prompt.Cancel) {
+		return closePrompt(m), nil
+	}
+
+	value := m.prompt.Value()
+	switch m.currentMode {
+	case SearchMode:
+		m = closePrompt(m)
+		return applySearch(m, value)
+
+	case TagMode:
+		m = closePrompt(m)
+		if m.currentIdx < 0 {
+			return m, nil
+		}
+		if err := m.store.SetTags(m.cmds[m.currentIdx].ID, strings.Fields(value)); err != nil {
+			return showError(m, "Failed to save tags", err)
+		}
+		if err := reloadCommands(&m); err != nil {
+			return showError(m, "Failed to get commands", err)
+		}
+		m.cmdsHistory.Select(m.currentIdx)
+
+	case AssertMode:
+		assertions, err := assert.Parse(value)
+		if err != nil {
+			m.promptErr = err.Error()
+			return m, nil
+		}
+		m = closePrompt(m)
+		if m.currentIdx < 0 {
+			return m, nil
+		}
+		if err := m.store.SetAssertions(m.cmds[m.currentIdx].ID, assertions); err != nil {
+			return showError(m, "Failed to save assertions", err)
+		}
+
+	case NormalizeMode:
+		normalizers, err := snapshot.Parse(value)
+		if err != nil {
+			m.promptErr = err.Error()
+			return m, nil
+		}
+		m = closePrompt(m)
+		if m.currentIdx < 0 {
+			return m, nil
+		}
+		if err := m.store.SetNormalizers(m.cmds[m.currentIdx].ID, normalizers); err != nil {
+			return showError(m, "Failed to save normalizers", err)
+		}
+
+	case FilterMode:
+		filter, err := store.ParseFilter(value)
+		if err != nil {
+			// Keep the prompt open so that the filter can be fixed
+			m.promptErr = err.Error()
+			return m, nil
+		}
+		m = closePrompt(m)
+		return applyFilter(m, filter), nil
+	}
+
+	return m, nil
+}
+
+func closePrompt(m Model) Model {
+	m.prompt.Blur()
+	m.promptErr = ""
+	m.currentMode = ViewMode
+	return m
+}
+
+// Filter the commands, moving the selection off the hidden ones
+func applyFilter(m Model, filter store.Filter) Model {
+	m.cmdsHistory.SetFilter(filter)
+	if m.cmdsHistory.IsVisible(m.currentIdx) {
+		return m
+	}
+
+	var ok bool
+	if m, ok = moveSelection(m, 1); ok {
+		return m
+	}
+	if m, ok = moveSelection(m, -1); ok {
+		return m
+	}
+
+	m.currentIdx = -1
+	m.cmdsHistory.ClearSelection()
+	return m
+}
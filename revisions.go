@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"cahier/diff"
+	"cahier/store"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const maxListedRevisions = 10
+
+var (
+	selectedRevisionStyle = lipgloss.NewStyle().Bold(true)
+
+	diffBoxStyle = lipgloss.NewStyle().
+			Padding(0, 1).
+			BorderStyle(lipgloss.RoundedBorder())
+)
+
+type revisionsState struct {
+	number     int // Cell number of the command
+	command    store.Command
+	revisions  []store.Revision // Most recent first
+	cursor     int              // Highlighted revision
+	base       int              // Revision the highlighted one is compared to
+	sideBySide bool
+}
+
+// Open the revision history of the current command
+func openRevisions(m Model) (Model, tea.Cmd) {
+	if m.currentIdx < 0 {
+		return m, nil
+	}
+
+	cmd := m.cmds[m.currentIdx]
+	revisions, err := m.store.GetRevisions(cmd.ID)
+	if err != nil {
+		return showError(m, "Failed to get revisions", err)
+	}
+	if len(revisions) == 0 {
+		return m, nil
+	}
+
+	// Compare the previous revision with the current one by default
+	m.revisions = revisionsState{
+		number:    m.currentIdx + 1,
+		command:   cmd,
+		revisions: revisions,
+		cursor:    min(1, len(revisions)-1),
+		base:      0,
+	}
+	m.currentMode = RevisionsMode
+	return m, nil
+}
+
+func HandleRevisionsModeKey(m Model, msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := m.keys.Revisions
+	r := &m.revisions
+
+	switch {
+	case key.Matches(msg, k.Up):
+		if r.cursor > 0 {
+			r.cursor--
+		}
+
+	case key.Matches(msg, k.Down):
+		if r.cursor < len(r.revisions)-1 {
+			r.cursor++
+		}
+
+	// Compare the other revisions to the highlighted one
+	case key.Matches(msg, k.Base):
+		r.base = r.cursor
+
+	// Switch between the unified and side by side diffs
+	case key.Matches(msg, k.SideBySide):
+		r.sideBySide = !r.sideBySide
+
+	// Restore the highlighted revision, which saves it as a new one. The
+	// cell is read again, as it may have run since the revisions opened.
+	case key.Matches(msg, k.Restore):
+		cmds, err := m.store.GetCommands()
+		if err != nil {
+			return showError(m, "Failed to get commands", err)
+		}
+		idx := slices.IndexFunc(cmds, func(cmd store.Command) bool { return cmd.ID == r.command.ID })
+		if idx < 0 {
+			m.currentMode = ViewMode
+			return showFlash(m, errorStyle.Render("The cell was deleted"))
+		}
+		cmd := cmds[idx]
+		cmd.Command = r.revisions[r.cursor].Command
+		if err := m.store.SaveCommand(cmd); err != nil {
+			return showError(m, "Failed to save command", err)
+		}
+		m.currentMode = ViewMode
+		return reloadAndSelect(m, m.currentIdx)
+
+	case key.Matches(msg, k.Close):
+		m.currentMode = ViewMode
+	}
+
+	return m, nil
+}
+
+func (r revisionsState) View(width int) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Revisions of cell %d\n\n", r.number))
+
+	// Keep the cursor within the visible window of revisions
+	start := max(r.cursor-maxListedRevisions+1, 0)
+	end := min(start+maxListedRevisions, len(r.revisions))
+	for i := start; i < end; i++ {
+		revision := r.revisions[i]
+
+		marker := "  "
+		if i == r.base {
+			marker = "◆ "
+		}
+		line := fmt.Sprintf("%s%3d  %s  %s", marker, len(r.revisions)-i,
+			revision.CreatedAt.Format("2006-01-02 15:04:05"),
+			strings.SplitN(revision.Command, "\n", 2)[0])
+		if i == r.cursor {
+			line = selectedRevisionStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(r.revisions) > end {
+		b.WriteString(faintStyle.Render(fmt.Sprintf("  … %d older", len(r.revisions)-end)) + "\n")
+	}
+
+	// Always diff from the older revision to the newer one
+	older, newer := r.revisions[max(r.cursor, r.base)], r.revisions[min(r.cursor, r.base)]
+	lines := diff.Lines(older.Command, newer.Command)
+
+	var content string
+	switch {
+	case !diff.Changed(lines):
+		content = faintStyle.Render("No difference")
+	case r.sideBySide:
+		content = diff.RenderSideBySide(lines, width-4)
+	default:
+		content = diff.Render(lines, -1)
+	}
+	b.WriteString("\n" + diffBoxStyle.Width(max(width-2, 20)).Render(content))
+
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"cahier/assert"
+	"cahier/config"
+	"cahier/executor"
+	"cahier/store"
+)
+
+// execOptions returns how the commands of a notebook run from its settings
+func execOptions(cfg config.Config) executor.Options {
+	return executor.Options{
+		Shell:     cfg.Shell,
+		Timeout:   cfg.Timeout.Duration,
+		MaxOutput: cfg.Output.MaxKB * 1024,
+	}
+}
+
+func newRun(cmdID int64, result executor.Result) store.Run {
+	chunks := make([]store.Chunk, len(result.Chunks))
+	for i, chunk := range result.Chunks {
+		chunks[i] = store.Chunk{Stream: chunk.Stream, Time: chunk.Time, Text: chunk.Data}
+	}
+
+	return store.Run{
+		CommandID: cmdID,
+		StartedAt: result.Started,
+		Duration:  result.Duration,
+		ExitCode:  result.ExitCode,
+		Output:    result.Output,
+		Chunks:    chunks,
+	}
+}
+
+// runStatus decides the status of a command after a run: a non-zero exit code
+// fails it, else its assertions are checked. The detail explains the failed
+// assertions.
+func runStatus(db *store.Store, run store.Run) (string, string) {
+	if run.ExitCode != 0 {
+		return store.StatusFailed, ""
+	}
+
+	assertions, err := db.GetAssertions(run.CommandID)
+	if err != nil {
+		log.Printf("Failed to get assertions: %v", err)
+		return store.StatusSuccess, ""
+	}
+	if len(assertions) == 0 {
+		return store.StatusSuccess, ""
+	}
+
+	result := assert.Result{Output: run.Output, Duration: run.Duration}
+	if goldenID, err := db.GetGoldenRunID(run.CommandID); err == nil && goldenID != 0 {
+		if golden, err := db.GetRun(goldenID); err == nil {
+			result.Golden = &golden.Output
+		}
+	}
+
+	failures := assert.Evaluate(assertions, result)
+	if len(failures) > 0 {
+		return store.StatusAssertionFailed, strings.Join(failures, "\n")
+	}
+	return store.StatusSuccess, ""
+}
+
+// recordRun saves the result of a command and updates the command status
+// accordingly, returning the run along with the new status and its detail
+func recordRun(db *store.Store, cmdID int64, result executor.Result) (store.Run, string, string) {
+	run := newRun(cmdID, result)
+	status, detail := runStatus(db, run)
+
+	if err := db.UpdateCommandStatus(cmdID, status, run.ExitCode); err != nil {
+		log.Printf("Failed to update command status: %v", err)
+	}
+	if detail != "" {
+		if err := db.SetStatusDetail(cmdID, detail); err != nil {
+			log.Printf("Failed to update command status: %v", err)
+		}
+	}
+	runID, err := db.AddRun(run)
+	if err != nil {
+		log.Printf("Failed to save run: %v", err)
+	}
+	run.ID = runID
+	auditRun(db, runID, run, result.Command)
+
+	return run, status, detail
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"cahier/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runAllState tracks a run of every cell, one after the other
+type runAllState struct {
+	queue   []int64 // IDs of the cells left to run
+	current int64   // ID of the running cell
+	total   int
+	started time.Time
+	ran     int
+	failed  int
+	skipped int // Cells the guard rules ask a confirmation for
+	invalid int // Cells not run for a syntax error
+}
+
+// loadLastRunAll reads the last run of every cell of a notebook, if any
+func loadLastRunAll(db *store.Store) *store.RunAll {
+	runAll, ok, err := db.GetLastRunAll()
+	if err != nil {
+		log.Printf("Failed to get the last run of every cell: %v", err)
+	}
+	if !ok {
+		return nil
+	}
+	return &runAll
+}
+
+// startRunAll runs every cell from the first one, each once the previous one
+// finished, like the run command does
+func startRunAll(m Model) (Model, tea.Cmd) {
+	if m.runAll != nil {
+		return showFlash(m, "Every cell is already running")
+	}
+	if len(m.cmds) == 0 {
+		return m, nil
+	}
+
+	state := &runAllState{total: len(m.cmds), started: time.Now()}
+	for _, cmd := range m.cmds {
+		state.queue = append(state.queue, cmd.ID)
+	}
+	m.runAll = state
+	return runNextCell(m)
+}
+
+// runNextCell starts the next cell of the run of every cell. Cells with a
+// syntax error are not run, and guarded cells are skipped rather than waiting
+// for a confirmation.
+func runNextCell(m Model) (Model, tea.Cmd) {
+	state := m.runAll
+	for len(state.queue) > 0 {
+		id := state.queue[0]
+		state.queue = state.queue[1:]
+
+		// The cell may have been deleted since
+		idx := slices.IndexFunc(m.cmds, func(cmd store.Command) bool { return cmd.ID == id })
+		if idx < 0 {
+			continue
+		}
+		if _, found := checkBeforeRun(m.store, m.cmds[idx].Command); found {
+			state.invalid++
+			continue
+		}
+		if len(guardReasons(m.store, m.cmds[idx])) > 0 {
+			state.skipped++
+			continue
+		}
+		state.current = id
+		return startRun(m, idx)
+	}
+	return finishRunAll(m)
+}
+
+// cellRan counts a cell of the run of every cell and starts the next one
+func cellRan(m Model, status string) (Model, tea.Cmd) {
+	m.runAll.ran++
+	if status != store.StatusSuccess {
+		m.runAll.failed++
+	}
+	return runNextCell(m)
+}
+
+// finishRunAll records the run of every cell and tells how it went
+func finishRunAll(m Model) (Model, tea.Cmd) {
+	state := m.runAll
+	m.runAll = nil
+
+	runAll := store.RunAll{StartedAt: state.started, Duration: time.Since(state.started), Cells: state.ran, Failed: state.failed}
+	if state.ran > 0 {
+		if err := m.store.SetLastRunAll(runAll); err != nil {
+			log.Printf("Failed to save the run of every cell: %v", err)
+		}
+		m.lastRunAll = &runAll
+	}
+
+	message := fmt.Sprintf("Ran %d cells in %s", state.ran, runAll.Duration.Round(time.Millisecond))
+	if state.failed > 0 {
+		message += fmt.Sprintf(", %d failed", state.failed)
+	}
+	if state.invalid > 0 {
+		message += fmt.Sprintf(", did not run %d with a syntax error", state.invalid)
+	}
+	if state.skipped > 0 {
+		message += fmt.Sprintf(", skipped %d that need a confirmation", state.skipped)
+	}
+	if state.failed > 0 || state.invalid > 0 || state.skipped > 0 {
+		message = errorStyle.Render(message)
+	}
+	return showFlash(m, message)
+}
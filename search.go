@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"cahier/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var slowSearchOnce sync.Once
+
+// warnSlowSearch logs once that search falls back to LIKE queries
+func warnSlowSearch(db *store.Store) {
+	if db.FullText() {
+		return
+	}
+	slowSearchOnce.Do(func() {
+		log.Printf("Search falls back to slower LIKE queries, build with -tags sqlite_fts5 for the full-text index")
+	})
+}
+
+type searchState struct {
+	query   string
+	matches map[int64]bool // IDs of the commands matching the query
+}
+
+// Run the search and jump to the first match
+func applySearch(m Model, query string) (Model, tea.Cmd) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return clearSearch(m), nil
+	}
+
+	warnSlowSearch(m.store)
+	results, err := m.store.Search(query)
+	if err != nil {
+		return showError(m, "Failed to search commands", err)
+	}
+
+	m.search.query = query
+	m.search.matches = make(map[int64]bool, len(results))
+	for _, result := range results {
+		m.search.matches[result.CommandID] = true
+	}
+	m.cmdsHistory.SetSearch(strings.Fields(query), m.search.matches)
+
+	// Stay on the current command if it matches
+	if m.cmdsHistory.IsVisible(m.currentIdx) && m.search.matches[m.cmds[m.currentIdx].ID] {
+		m.cmdsHistory.Select(m.currentIdx)
+		return m, nil
+	}
+	return nextSearchMatch(m, 1), nil
+}
+
+// Select the next command matching the search in the given direction,
+// wrapping around the ends of the list
+func nextSearchMatch(m Model, direction int) Model {
+	n := len(m.cmds)
+	if len(m.search.matches) == 0 || n == 0 {
+		return m
+	}
+
+	start := m.currentIdx
+	if start < 0 {
+		start = n - 1
+	}
+
+	for step := 1; step <= n; step++ {
+		idx := ((start+direction*step)%n + n) % n
+		if m.search.matches[m.cmds[idx].ID] && m.cmdsHistory.IsVisible(idx) {
+			m.currentIdx = idx
+			m.cmdsHistory.Select(idx)
+			break
+		}
+	}
+
+	return m
+}
+
+func clearSearch(m Model) Model {
+	m.search.query = ""
+	m.search.matches = nil
+	m.cmdsHistory.SetSearch(nil, nil)
+	return m
+}
@@ -0,0 +1,109 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Built-in normalizers, replacing values that change between runs by placeholders
+var builtins = map[string][]replacement{
+	"timestamps": {
+		{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<TIMESTAMP>"},
+		{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(\.\d+)?\b`), "<TIME>"},
+	},
+	"uuids": {
+		{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<UUID>"},
+	},
+	"tmppaths": {
+		{regexp.MustCompile(tempDirPattern()), "<TMP>"},
+	},
+}
+
+// regexPrefix introduces a custom normalizer masking every match of a regexp
+const regexPrefix = "regex:"
+
+type replacement struct {
+	re   *regexp.Regexp
+	with string
+}
+
+func tempDirPattern() string {
+	dirs := []string{regexp.QuoteMeta("/tmp/"), regexp.QuoteMeta("/var/folders/")}
+	if tmp := strings.TrimSuffix(os.TempDir(), "/"); tmp != "/tmp" {
+		dirs = append(dirs, regexp.QuoteMeta(tmp+"/"))
+	}
+	return `(` + strings.Join(dirs, "|") + `)[^\s'"]*`
+}
+
+// Names lists the built-in normalizers
+func Names() []string {
+	return []string{"timestamps", "uuids", "tmppaths"}
+}
+
+// Validate checks that a normalizer is built-in or a valid regex:<pattern>
+func Validate(spec string) error {
+	_, err := replacements(spec)
+	return err
+}
+
+func replacements(spec string) ([]replacement, error) {
+	if pattern, ok := strings.CutPrefix(spec, regexPrefix); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %v", pattern, err)
+		}
+		return []replacement{{re, "<MASKED>"}}, nil
+	}
+
+	if r, ok := builtins[spec]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("unknown normalizer %q, expected %s or %s<pattern>",
+		spec, strings.Join(Names(), ", "), regexPrefix)
+}
+
+// Parse reads built-in normalizers separated by spaces, possibly followed by
+// a regex:<pattern> taking the rest of the line, so that the pattern may hold
+// spaces. A single regexp can still mask several values with alternations.
+func Parse(s string) ([]string, error) {
+	specs := []string{}
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		if strings.HasPrefix(rest, regexPrefix) {
+			if err := Validate(rest); err != nil {
+				return nil, err
+			}
+			return append(specs, rest), nil
+		}
+
+		spec, after, _ := strings.Cut(rest, " ")
+		if err := Validate(spec); err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+		rest = strings.TrimSpace(after)
+	}
+	return specs, nil
+}
+
+// Format writes normalizers back in the form read by Parse
+func Format(specs []string) string {
+	return strings.Join(specs, " ")
+}
+
+// Normalize applies the normalizers to an output, in order. Invalid
+// normalizers are ignored, they are rejected when configured.
+func Normalize(output string, specs []string) string {
+	for _, spec := range specs {
+		r, err := replacements(spec)
+		if err != nil {
+			continue
+		}
+		for _, rep := range r {
+			output = rep.re.ReplaceAllString(output, rep.with)
+		}
+	}
+	return output
+}
@@ -0,0 +1,65 @@
+package snapshot
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "  ", []string{}, false},
+		{"builtins", "timestamps  uuids", []string{"timestamps", "uuids"}, false},
+		{"regex", `regex:id=\d+`, []string{`regex:id=\d+`}, false},
+		{"regex with spaces", "uuids regex:took \\d+ ms ", []string{"uuids", "regex:took \\d+ ms"}, false},
+		{"regex keeps builtin names", "regex:uuids timestamps", []string{"regex:uuids timestamps"}, false},
+		{"unknown", "timestamps dates", nil, true},
+		{"invalid regex", "regex:took (", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if err == nil {
+				again, err := Parse(Format(got))
+				if err != nil || !slices.Equal(again, got) {
+					t.Errorf("Parse(Format(%q)) = %q, %v", got, again, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		specs  []string
+		want   string
+	}{
+		{"none", "at 12:30:01", nil, "at 12:30:01"},
+		{"timestamp", "at 2024-05-01T12:30:01.5Z done", []string{"timestamps"}, "at <TIMESTAMP> done"},
+		{"time", "at 12:30:01 done", []string{"timestamps"}, "at <TIME> done"},
+		{"uuid", "id 123e4567-e89b-12d3-a456-426614174000", []string{"uuids"}, "id <UUID>"},
+		{"tmp path", "wrote /tmp/build-42/out.txt", []string{"tmppaths"}, "wrote <TMP>"},
+		{"regex with spaces", "took 42 ms", []string{"regex:took \\d+ ms"}, "<MASKED>"},
+		{"in order", "at 12:30:01", []string{"timestamps", "regex:<TIME>"}, "at <MASKED>"},
+		{"invalid ignored", "id 7", []string{"regex:(", "regex:\\d"}, "id <MASKED>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.output, tt.specs); got != tt.want {
+				t.Errorf("Normalize(%q, %q) = %q, want %q", tt.output, tt.specs, got, tt.want)
+			}
+		})
+	}
+}
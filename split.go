@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"cahier/inspector"
+	"cahier/store"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// minSplitWidth is the narrowest terminal showing the inspector next to the
+// cells, narrower ones show the cells alone
+const minSplitWidth = 120
+
+// inspectorState holds the latest runs of the cell shown in the inspector
+type inspectorState struct {
+	cmdID int64
+	runID int64 // Last run when the runs were read
+	runs  []store.Run
+}
+
+// splitActive reports whether the inspector is shown next to the cells
+func splitActive(m Model) bool {
+	return m.split && m.width >= minSplitWidth
+}
+
+// historyWidth returns the width of the cells, a bit more than half the
+// terminal when the inspector is shown
+func historyWidth(m Model) int {
+	if !splitActive(m) {
+		return m.width
+	}
+	return m.width * 55 / 100
+}
+
+// toggleSplit shows or hides the inspector
+func toggleSplit(m Model) (Model, tea.Cmd) {
+	m.split = !m.split
+	m.cmdsHistory.SetWidth(historyWidth(m))
+	if m.split && !splitActive(m) {
+		return showFlash(m, fmt.Sprintf("The inspector shows on terminals at least %d columns wide", minSplitWidth))
+	}
+	return m, nil
+}
+
+// refreshInspector reads the runs of the selected cell when the selection or
+// its last run changed
+func refreshInspector(m Model) (Model, tea.Cmd) {
+	if !splitActive(m) || m.currentIdx < 0 || m.currentIdx >= len(m.cmds) {
+		return m, nil
+	}
+
+	cmdID := m.cmds[m.currentIdx].ID
+	run, _ := m.cmdsHistory.LastRun(m.currentIdx)
+	if cmdID == m.inspector.cmdID && run.ID == m.inspector.runID {
+		return m, nil
+	}
+
+	// The cell is remembered even when reading failed, so that the error flashes once
+	runs, err := m.store.GetRunTimes(cmdID, inspector.MaxRuns)
+	m.inspector = inspectorState{cmdID: cmdID, runID: run.ID, runs: runs}
+	if err != nil {
+		return showError(m, "Failed to get runs", err)
+	}
+	return m, nil
+}
+
+// viewCells shows the cells, next to the inspector when the split is active
+func viewCells(m Model) string {
+	if !splitActive(m) {
+		return m.cmdsHistory.View()
+	}
+
+	cell := inspector.Cell{}
+	if m.currentIdx >= 0 && m.currentIdx < len(m.cmds) && m.cmds[m.currentIdx].ID == m.inspector.cmdID {
+		cell.Number = m.currentIdx + 1
+		cell.Command = m.cmds[m.currentIdx]
+		cell.Runs = m.inspector.runs
+		cell.Output, _ = m.cmdsHistory.Output(m.currentIdx)
+	}
+
+	width := historyWidth(m)
+	return lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.PlaceHorizontal(width, lipgloss.Left, m.cmdsHistory.View()),
+		" ",
+		inspector.Render(cell, m.width-width-1, m.cmdsHistory.Height()))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cahier/store"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Colors come from the theme, see applyTheme
+var (
+	passedStyle  = lipgloss.NewStyle()
+	failedStyle  = lipgloss.NewStyle()
+	runningStyle = lipgloss.NewStyle()
+)
+
+// statusBarSeparator separates the parts of the status bar
+const statusBarSeparator = "  │  "
+
+// workingDir returns the directory the commands run in, shortened under the home directory
+func workingDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "?"
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if rest, err := filepath.Rel(home, dir); err == nil && !strings.HasPrefix(rest, "..") {
+			return filepath.Join("~", rest)
+		}
+	}
+	return dir
+}
+
+// statusBar shows the notebook, where its commands run, how its cells did and
+// how long running all of them took
+func statusBar(m Model) string {
+	passed, failed, running := 0, 0, 0
+	for _, cmd := range m.cmds {
+		switch cmd.Status {
+		case store.StatusSuccess:
+			passed++
+		case store.StatusFailed, store.StatusAssertionFailed:
+			failed++
+		case store.StatusRunning:
+			running++
+		}
+	}
+
+	parts := []string{faintStyle.Render(m.store.Name())}
+	if profile := os.Getenv(profileEnv); profile != "" {
+		parts = append(parts, faintStyle.Render("profile "+profile))
+	}
+	parts = append(parts, faintStyle.Render(m.cwd))
+
+	counts := passedStyle.Render(fmt.Sprintf("%d passed", passed)) + " " +
+		failedStyle.Render(fmt.Sprintf("%d failed", failed))
+	if running > 0 {
+		counts += " " + runningStyle.Render(fmt.Sprintf("%d running", running))
+	}
+	parts = append(parts, counts)
+
+	switch {
+	case m.runAll != nil:
+		parts = append(parts, runningStyle.Render(fmt.Sprintf("running all, %d of %d", m.runAll.total-len(m.runAll.queue), m.runAll.total)))
+	case m.lastRunAll != nil:
+		parts = append(parts, faintStyle.Render(fmt.Sprintf("all cells in %s", m.lastRunAll.Duration.Round(time.Millisecond))))
+	}
+	parts = append(parts, faintStyle.Render(m.store.Path()))
+
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(strings.Join(parts, faintStyle.Render(statusBarSeparator)))
+}
@@ -0,0 +1,60 @@
+package store
+
+// Kinds of assertions checked on the output of a command after it ran
+const (
+	AssertContains = "contains" // Output contains Value
+	AssertMatches  = "matches"  // Output matches the regular expression in Value
+	AssertGolden   = "golden"   // Output equals the output of the golden run
+	AssertJSON     = "json"     // Value at the JSON path Path of the output equals Value
+	AssertUnder    = "under"    // Run took less than the duration in Value
+)
+
+// Assertion is an expectation on the result of a command
+type Assertion struct {
+	Kind  string
+	Path  string
+	Value string
+}
+
+// SetAssertions replaces the assertions of a command
+func (s *Store) SetAssertions(commandID int64, assertions []Assertion) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM assertions WHERE command_id = ?`, commandID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO assertions (command_id, position, kind, path, value) VALUES (?, ?, ?, ?, ?)`
+	for i, assertion := range assertions {
+		if _, err := tx.Exec(query, commandID, i, assertion.Kind, assertion.Path, assertion.Value); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAssertions returns the assertions of a command in the order they were written
+func (s *Store) GetAssertions(commandID int64) ([]Assertion, error) {
+	rows, err := s.conn.Query(`SELECT kind, path, value FROM assertions
+		WHERE command_id = ? ORDER BY position`, commandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assertions := []Assertion{}
+	for rows.Next() {
+		var assertion Assertion
+		if err := rows.Scan(&assertion.Kind, &assertion.Path, &assertion.Value); err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, assertion)
+	}
+
+	return assertions, rows.Err()
+}
@@ -0,0 +1,241 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AuditRun     = "run"     // A command ran
+	AuditConfirm = "confirm" // A guarded command was confirmed before running
+)
+
+// AuditEntry records what ran, by whom and from where. Entries are chained
+// by hash, so that editing or removing one breaks the chain after it.
+type AuditEntry struct {
+	ID        int64
+	CreatedAt time.Time
+	Kind      string
+	CommandID int64
+	RunID     int64
+	Command   string
+	User      string
+	Host      string
+	Dir       string
+	Profile   string
+	Detail    string
+	PrevHash  string
+	Hash      string
+}
+
+// AuditFilter selects audit entries, zero fields matching everything
+type AuditFilter struct {
+	Kind      string
+	User      string
+	CommandID int64
+	Since     time.Time
+}
+
+// auditTriggers make the audit table append-only
+var auditTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS audit_no_update BEFORE UPDATE ON audit BEGIN
+		SELECT RAISE(ABORT, 'the audit log is append-only');
+	END;`,
+	`CREATE TRIGGER IF NOT EXISTS audit_no_delete BEFORE DELETE ON audit BEGIN
+		SELECT RAISE(ABORT, 'the audit log is append-only');
+	END;`,
+}
+
+// hash chains an entry to the previous one
+func (e AuditEntry) hash() string {
+	fields := []string{
+		e.PrevHash,
+		strconv.FormatInt(e.CreatedAt.UnixNano(), 10),
+		e.Kind,
+		strconv.FormatInt(e.CommandID, 10),
+		strconv.FormatInt(e.RunID, 10),
+		e.Command, e.User, e.Host, e.Dir, e.Profile, e.Detail,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// auditChainedSetting is set once the entries written before the audit log
+// was hashed are chained, so that blanked hashes are never rebuilt afterwards
+const auditChainedSetting = "audit.chained"
+
+// initAudit chains the entries written before the audit log was hashed, once,
+// then locks the table
+func (s *Store) initAudit() error {
+	for _, column := range []string{"run_id integer default 0", "dir text default ''", "profile text default ''",
+		"prev_hash text default ''", "hash text default ''"} {
+		name, definition, _ := strings.Cut(column, " ")
+		if err := s.addColumn("audit", name, definition); err != nil {
+			return err
+		}
+	}
+
+	chained, err := s.GetSetting(auditChainedSetting)
+	if err != nil {
+		return err
+	}
+	if chained == "" {
+		// Logs locked by the triggers were hashed from their first entry
+		var locked int
+		err := s.conn.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'audit_no_update'`).Scan(&locked)
+		if err != nil {
+			return err
+		}
+		if locked == 0 {
+			if err := s.chainAudit(); err != nil {
+				return err
+			}
+		}
+		if err := s.SetSetting(auditChainedSetting, "1"); err != nil {
+			return err
+		}
+	}
+
+	for _, query := range auditTriggers {
+		if _, err := s.conn.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chainAudit hashes the entries written before the audit log was hashed
+func (s *Store) chainAudit() error {
+	entries, err := s.GetAuditEntries(AuditFilter{})
+	if err != nil {
+		return err
+	}
+	prev := ""
+	for _, entry := range entries {
+		if entry.Hash == "" {
+			entry.PrevHash = prev
+			entry.Hash = entry.hash()
+			_, err := s.conn.Exec(`UPDATE audit SET prev_hash = ?, hash = ? WHERE id = ?`,
+				entry.PrevHash, entry.Hash, entry.ID)
+			if err != nil {
+				return err
+			}
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
+
+// AddAuditEntry appends an entry to the audit log, chained to the last one.
+// The write lock is taken before reading the last hash, so that two processes
+// appending at the same time cannot both chain to it.
+func (s *Store) AddAuditEntry(entry AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	ctx := context.Background()
+	conn, err := s.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, `ROLLBACK`)
+		}
+	}()
+
+	err = conn.QueryRowContext(ctx, `SELECT hash FROM audit ORDER BY id DESC LIMIT 1`).Scan(&entry.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	entry.Hash = entry.hash()
+
+	_, err = conn.ExecContext(ctx, `INSERT INTO audit (created_at, kind, command_id, run_id, command, user, host, dir, profile, detail, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.CreatedAt.UnixNano(), entry.Kind, entry.CommandID, entry.RunID, entry.Command,
+		entry.User, entry.Host, entry.Dir, entry.Profile, entry.Detail, entry.PrevHash, entry.Hash)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// GetAuditEntries returns the entries matching the filter, oldest first
+func (s *Store) GetAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	conditions := []string{"1 = 1"}
+	args := []any{}
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+	if filter.User != "" {
+		conditions = append(conditions, "user = ?")
+		args = append(args, filter.User)
+	}
+	if filter.CommandID != 0 {
+		conditions = append(conditions, "command_id = ?")
+		args = append(args, filter.CommandID)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UnixNano())
+	}
+
+	rows, err := s.conn.Query(`SELECT id, created_at, kind, command_id, run_id, command, user, host,
+		dir, profile, detail, prev_hash, hash
+		FROM audit WHERE `+strings.Join(conditions, " AND ")+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var createdAt int64
+		err := rows.Scan(&entry.ID, &createdAt, &entry.Kind, &entry.CommandID, &entry.RunID, &entry.Command,
+			&entry.User, &entry.Host, &entry.Dir, &entry.Profile, &entry.Detail, &entry.PrevHash, &entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = time.Unix(0, createdAt)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// VerifyAudit checks the hash chain of the whole audit log, returning the ID
+// of the first entry that was changed, unhashed, or whose predecessor was
+// removed, or zero when the log is intact
+func (s *Store) VerifyAudit() (int64, error) {
+	entries, err := s.GetAuditEntries(AuditFilter{})
+	if err != nil {
+		return 0, err
+	}
+
+	prev := ""
+	for _, entry := range entries {
+		if entry.Hash == "" || entry.PrevHash != prev || entry.hash() != entry.Hash {
+			return entry.ID, nil
+		}
+		prev = entry.Hash
+	}
+	return 0, nil
+}
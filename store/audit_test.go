@@ -0,0 +1,147 @@
+package store
+
+import (
+	"testing"
+)
+
+// addAuditEntries appends entries for the commands, returning their IDs
+func addAuditEntries(t *testing.T, s *Store, commands ...string) []int64 {
+	t.Helper()
+	for i, command := range commands {
+		entry := AuditEntry{Kind: AuditRun, CommandID: int64(i + 1), Command: command, User: "ada", Host: "lab"}
+		if err := s.AddAuditEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := s.GetAuditEntries(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int64{}
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+// unlockAudit drops the triggers keeping the audit table append-only, the
+// way someone tampering with the file would
+func unlockAudit(t *testing.T, s *Store) {
+	t.Helper()
+	for _, trigger := range []string{"audit_no_update", "audit_no_delete"} {
+		if _, err := s.conn.Exec(`DROP TRIGGER ` + trigger); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyAudit(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper string // Query run on the audit table, ? being the ID of the second entry
+		want   int    // Index of the entry reported, -1 when intact
+	}{
+		{"intact", "", -1},
+		{"changed command", `UPDATE audit SET command = 'rm -rf /' WHERE id = ?`, 1},
+		{"changed time", `UPDATE audit SET created_at = created_at + 1 WHERE id = ?`, 1},
+		{"forged hash", `UPDATE audit SET command = 'ls', hash = 'forged' WHERE id = ?`, 1},
+		{"removed entry", `DELETE FROM audit WHERE id = ?`, 2}, // Reported on the next entry
+		{"unchained entry", `UPDATE audit SET prev_hash = '' WHERE id = ?`, 1},
+		{"blanked hash", `UPDATE audit SET hash = '' WHERE id = ?`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			ids := addAuditEntries(t, s, "make build", "make deploy", "make test")
+			if tt.tamper != "" {
+				unlockAudit(t, s)
+				if _, err := s.conn.Exec(tt.tamper, ids[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want := int64(0)
+			if tt.want >= 0 {
+				want = ids[tt.want]
+			}
+			got, err := s.VerifyAudit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("VerifyAudit = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestAuditAppendOnly(t *testing.T) {
+	s := newTestStore(t)
+	ids := addAuditEntries(t, s, "make build")
+	if _, err := s.conn.Exec(`UPDATE audit SET command = 'ls' WHERE id = ?`, ids[0]); err == nil {
+		t.Error("an audit entry was updated")
+	}
+	if _, err := s.conn.Exec(`DELETE FROM audit WHERE id = ?`, ids[0]); err == nil {
+		t.Error("an audit entry was deleted")
+	}
+}
+
+func TestInitAuditChainsOldEntries(t *testing.T) {
+	s := newTestStore(t)
+	addAuditEntries(t, s, "make build", "make test")
+
+	// Entries written before the log was hashed have no hash, and the
+	// notebook has neither the triggers nor the chained setting
+	unlockAudit(t, s)
+	if _, err := s.conn.Exec(`UPDATE audit SET prev_hash = '', hash = ''`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.conn.Exec(`DELETE FROM settings WHERE key = ?`, auditChainedSetting); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.initAudit(); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.VerifyAudit(); err != nil || id != 0 {
+		t.Errorf("VerifyAudit = %d, %v, want the old entries chained", id, err)
+	}
+	addAuditEntries(t, s, "make deploy")
+	if id, err := s.VerifyAudit(); err != nil || id != 0 {
+		t.Errorf("VerifyAudit = %d, %v, want new entries chained to the old ones", id, err)
+	}
+}
+
+func TestInitAuditKeepsBlankedHashes(t *testing.T) {
+	tests := []struct {
+		name   string
+		locked bool // Whether the triggers are back when the notebook opens again
+	}{
+		{"unlocked", false},
+		{"locked again", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			ids := addAuditEntries(t, s, "make build", "make deploy")
+
+			unlockAudit(t, s)
+			if _, err := s.conn.Exec(`UPDATE audit SET command = 'ls', prev_hash = '', hash = '' WHERE id = ?`, ids[1]); err != nil {
+				t.Fatal(err)
+			}
+			if tt.locked {
+				for _, query := range auditTriggers {
+					if _, err := s.conn.Exec(query); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if err := s.initAudit(); err != nil {
+				t.Fatalf("initAudit: %v", err)
+			}
+			if id, err := s.VerifyAudit(); err != nil || id != ids[1] {
+				t.Errorf("VerifyAudit = %d, %v, want the blanked entry %d reported", id, err, ids[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// InlineOutputLimit is the size above which run outputs are moved out of the
+// database into compressed blob files. The database keeps their beginning,
+// which search still finds.
+const InlineOutputLimit = 64 * 1024
+
+// BlobDir returns the directory next to the notebook database holding its blobs
+func (s *Store) BlobDir() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".blobs"
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.BlobDir(), hash+".gz")
+}
+
+// writeBlob stores the gzip compressed output in a file named after its
+// hash, so identical outputs share a file, and returns the hash
+func (s *Store) writeBlob(output string) (string, error) {
+	sum := sha256.Sum256([]byte(output))
+	hash := hex.EncodeToString(sum[:])
+
+	path := s.blobPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(s.BlobDir(), 0o755); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(output)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	// Write then rename so a crash never leaves a truncated blob behind
+	tmp, err := os.CreateTemp(s.BlobDir(), hash+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return hash, os.Rename(tmp.Name(), path)
+}
+
+// readBlob returns the output stored in a blob file
+func (s *Store) readBlob(hash string) (string, error) {
+	file, err := os.Open(s.blobPath(hash))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	return string(data), err
+}
+
+// preview cuts the output to the inline limit without splitting a character
+func preview(output string) string {
+	if len(output) <= InlineOutputLimit {
+		return output
+	}
+	cut := InlineOutputLimit
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return output[:cut]
+}
@@ -4,11 +4,15 @@ const (
 	StatusRunning = "running"
 	StatusSuccess = "success"
 	StatusFailed  = "failed"
+
+	StatusAssertionFailed = "assertion_failed"
 )
 
 type Command struct {
-	ID         int64
-	Command    string
-	Status     string
-	ReturnCode int
+	ID           int64
+	Command      string
+	Status       string
+	ReturnCode   int
+	StatusDetail string // Explains the status, such as the failed assertions
+	Tags         []string
 }
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StatusNeverRun matches the commands that were never executed, whose status is empty
+const StatusNeverRun = "never"
+
+// Filter restricts a list of commands to the ones carrying every tag and
+// having the given status. The zero value matches every command.
+type Filter struct {
+	Tags   []string
+	Status string
+}
+
+// ParseFilter reads a filter from space separated terms: '#tag' for tags and
+// one of failed, assertion_failed, success, running or never for the status
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	for _, term := range strings.Fields(s) {
+		switch {
+		case strings.HasPrefix(term, "#"):
+			if tag := NormalizeTag(term); tag != "" {
+				f.Tags = append(f.Tags, tag)
+			}
+		case term == StatusFailed, term == StatusAssertionFailed, term == StatusSuccess,
+			term == StatusRunning, term == StatusNeverRun:
+			f.Status = term
+		default:
+			return Filter{}, fmt.Errorf("unknown filter %q, expected #tag, %s, %s, %s, %s or %s",
+				term, StatusFailed, StatusAssertionFailed, StatusSuccess, StatusRunning, StatusNeverRun)
+		}
+	}
+	return f, nil
+}
+
+func (f Filter) IsZero() bool {
+	return len(f.Tags) == 0 && f.Status == ""
+}
+
+func (f Filter) Matches(cmd Command) bool {
+	switch f.Status {
+	case "":
+	case StatusNeverRun:
+		if cmd.Status != "" {
+			return false
+		}
+	default:
+		if cmd.Status != f.Status {
+			return false
+		}
+	}
+
+	for _, tag := range f.Tags {
+		if !slices.Contains(cmd.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Filter) String() string {
+	terms := []string{}
+	for _, tag := range f.Tags {
+		terms = append(terms, "#"+tag)
+	}
+	if f.Status != "" {
+		terms = append(terms, f.Status)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+)
+
+// NotebookName returns the name of the notebook stored at path
+func NotebookName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Notebooks returns the paths of the notebook databases found in dir. Files
+// that are not SQLite databases holding a commands table are skipped.
+func Notebooks(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	notebooks := []string{}
+	for _, path := range paths {
+		if isNotebook(path) {
+			notebooks = append(notebooks, path)
+		}
+	}
+
+	return notebooks, nil
+}
+
+func isNotebook(path string) bool {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	var count int
+	err = conn.QueryRow(`SELECT count(*) FROM sqlite_master
+		WHERE type = 'table' AND name = 'commands'`).Scan(&count)
+	return err == nil && count == 1
+}
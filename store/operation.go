@@ -0,0 +1,223 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Kinds of operations recorded in the operation log
+const (
+	OpInsert = "insert"
+	OpEdit   = "edit"
+	OpDelete = "delete"
+	OpMove   = "move"
+)
+
+// Operation is a mutation of the notebook that can be undone and redone
+type Operation struct {
+	ID        int64
+	Kind      string
+	CommandID int64 // Command the operation was applied to
+}
+
+// snapshot is the state of a command before or after an operation
+type snapshot struct {
+	ID         int64  `json:"id"`
+	Command    string `json:"command"`
+	Status     string `json:"status"`
+	ReturnCode int    `json:"return_code"`
+	Position   int64  `json:"position"`
+}
+
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func getSnapshot(q querier, id int64) (snapshot, bool, error) {
+	var snap snapshot
+	err := q.QueryRow(`SELECT id, command, status, return_code, position FROM commands WHERE id = ?`, id).
+		Scan(&snap.ID, &snap.Command, &snap.Status, &snap.ReturnCode, &snap.Position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return snapshot{}, false, nil
+	}
+	if err != nil {
+		return snapshot{}, false, err
+	}
+	return snap, true, nil
+}
+
+// logOperation appends an operation to the log. Operations that were undone
+// can no longer be redone once the notebook changes.
+func logOperation(tx *sql.Tx, kind string, commandID int64, before, after []snapshot) error {
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM operations WHERE undone = 1`); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO operations (kind, command_id, before, after, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+	_, err = tx.Exec(query, kind, commandID, string(beforeJSON), string(afterJSON), time.Now().UnixNano())
+	return err
+}
+
+// DeleteCommand removes a command from the notebook. Its runs and tags are
+// kept so that undoing the deletion brings them back.
+func (s *Store) DeleteCommand(id int64) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, found, err := getSnapshot(tx, id)
+	if err != nil || !found {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM commands WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := logOperation(tx, OpDelete, id, []snapshot{before}, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MoveCommand swaps a command with its previous (direction < 0) or next
+// neighbour, returning false when it is already at that end of the notebook
+func (s *Store) MoveCommand(id int64, direction int) (bool, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	current, found, err := getSnapshot(tx, id)
+	if err != nil || !found {
+		return false, err
+	}
+
+	query := `SELECT id FROM commands WHERE position > ? ORDER BY position LIMIT 1`
+	if direction < 0 {
+		query = `SELECT id FROM commands WHERE position < ? ORDER BY position DESC LIMIT 1`
+	}
+	var neighbourID int64
+	if err := tx.QueryRow(query, current.Position).Scan(&neighbourID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	neighbour, _, err := getSnapshot(tx, neighbourID)
+	if err != nil {
+		return false, err
+	}
+
+	before := []snapshot{current, neighbour}
+	current.Position, neighbour.Position = neighbour.Position, current.Position
+	after := []snapshot{current, neighbour}
+
+	if err := applySnapshots(tx, before, after); err != nil {
+		return false, err
+	}
+	if err := logOperation(tx, OpMove, id, before, after); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// applySnapshots moves the commands from the from state to the to state:
+// commands only present in from are deleted, the others are upserted. The
+// status of commands that still exist is left untouched.
+func applySnapshots(tx *sql.Tx, from, to []snapshot) error {
+	kept := map[int64]bool{}
+	for _, snap := range to {
+		kept[snap.ID] = true
+	}
+
+	for _, snap := range from {
+		if !kept[snap.ID] {
+			if _, err := tx.Exec(`DELETE FROM commands WHERE id = ?`, snap.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	query := `INSERT INTO commands (id, command, status, return_code, position)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE
+		SET command=excluded.command,
+		    position=excluded.position;`
+	for _, snap := range to {
+		if _, err := tx.Exec(query, snap.ID, snap.Command, snap.Status, snap.ReturnCode, snap.Position); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Undo reverts the last operation that was not undone yet. It returns false
+// when there is nothing to undo.
+func (s *Store) Undo() (Operation, bool, error) {
+	return s.replay(`SELECT id, kind, command_id, before, after FROM operations
+		WHERE undone = 0 ORDER BY id DESC LIMIT 1`, true)
+}
+
+// Redo applies again the last undone operation. It returns false when there
+// is nothing to redo.
+func (s *Store) Redo() (Operation, bool, error) {
+	return s.replay(`SELECT id, kind, command_id, before, after FROM operations
+		WHERE undone = 1 ORDER BY id LIMIT 1`, false)
+}
+
+func (s *Store) replay(query string, undo bool) (Operation, bool, error) {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return Operation{}, false, err
+	}
+	defer tx.Rollback()
+
+	var op Operation
+	var beforeJSON, afterJSON string
+	err = tx.QueryRow(query).Scan(&op.ID, &op.Kind, &op.CommandID, &beforeJSON, &afterJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Operation{}, false, nil
+	}
+	if err != nil {
+		return Operation{}, false, err
+	}
+
+	var before, after []snapshot
+	if err := json.Unmarshal([]byte(beforeJSON), &before); err != nil {
+		return Operation{}, false, err
+	}
+	if err := json.Unmarshal([]byte(afterJSON), &after); err != nil {
+		return Operation{}, false, err
+	}
+
+	from, to, undone := before, after, 0
+	if undo {
+		from, to, undone = after, before, 1
+	}
+	if err := applySnapshots(tx, from, to); err != nil {
+		return Operation{}, false, err
+	}
+	if _, err := tx.Exec(`UPDATE operations SET undone = ? WHERE id = ?`, undone, op.ID); err != nil {
+		return Operation{}, false, err
+	}
+
+	return op, true, tx.Commit()
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Retention limits the runs a notebook keeps. Zero values mean no limit.
+// The latest and golden runs of every command of the notebook are always
+// kept, deleted commands losing that protection.
+type Retention struct {
+	KeepRuns int // Runs kept per command
+	MaxMB    int // Size of the stored outputs, blobs included
+}
+
+// PruneResult counts what Prune removed
+type PruneResult struct {
+	Runs  int
+	Blobs int
+}
+
+// GetRetention returns the retention of the notebook
+func (s *Store) GetRetention() (Retention, error) {
+	var retention Retention
+	for key, dest := range map[string]*int{"retention.keep_runs": &retention.KeepRuns, "retention.max_mb": &retention.MaxMB} {
+		value, err := s.GetSetting(key)
+		if err != nil {
+			return retention, err
+		}
+		if value == "" {
+			continue
+		}
+		if *dest, err = strconv.Atoi(value); err != nil {
+			return retention, err
+		}
+	}
+	return retention, nil
+}
+
+// SetRetention changes the retention of the notebook
+func (s *Store) SetRetention(retention Retention) error {
+	if err := s.SetSetting("retention.keep_runs", strconv.Itoa(max(retention.KeepRuns, 0))); err != nil {
+		return err
+	}
+	return s.SetSetting("retention.max_mb", strconv.Itoa(max(retention.MaxMB, 0)))
+}
+
+// protectedRuns is the condition matching the runs retention never removes
+const protectedRuns = `(id IN (SELECT golden_run_id FROM commands)
+	OR (command_id IN (SELECT id FROM commands)
+		AND started_at = (SELECT max(started_at) FROM runs AS latest WHERE latest.command_id = runs.command_id)))`
+
+// pruneCommandRuns removes the runs of a command beyond the most recent ones
+func (s *Store) pruneCommandRuns(commandID int64, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	_, err := s.conn.Exec(`DELETE FROM runs WHERE command_id = ? AND NOT `+protectedRuns+`
+		AND id NOT IN (SELECT id FROM runs WHERE command_id = ? ORDER BY started_at DESC LIMIT ?)`,
+		commandID, commandID, keep)
+	return err
+}
+
+// Prune applies the retention to every command, removes the oldest runs
+// while the outputs are over the size limit, then deletes the blob files no
+// run uses anymore
+func (s *Store) Prune(retention Retention) (PruneResult, error) {
+	var result PruneResult
+
+	if retention.KeepRuns > 0 {
+		res, err := s.conn.Exec(`DELETE FROM runs WHERE NOT `+protectedRuns+`
+			AND id NOT IN (
+				SELECT id FROM (
+					SELECT id, row_number() OVER (PARTITION BY command_id ORDER BY started_at DESC) AS rank
+					FROM runs
+				) WHERE rank <= ?
+			)`, retention.KeepRuns)
+		if err != nil {
+			return result, err
+		}
+		removed, _ := res.RowsAffected()
+		result.Runs += int(removed)
+	}
+
+	if retention.MaxMB > 0 {
+		removed, err := s.pruneToSize(int64(retention.MaxMB) * 1024 * 1024)
+		if err != nil {
+			return result, err
+		}
+		result.Runs += removed
+	}
+
+	var err error
+	result.Blobs, err = s.removeUnusedBlobs()
+	return result, err
+}
+
+// pruneToSize removes the oldest runs until the outputs fit in limit bytes
+func (s *Store) pruneToSize(limit int64) (int, error) {
+	type stored struct {
+		id        int64
+		size      int64
+		protected bool
+	}
+
+	rows, err := s.conn.Query(`SELECT id, length(output), blob, ` + protectedRuns + `
+		FROM runs ORDER BY started_at`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var runs []stored
+	var total int64
+	newest := map[string]int{} // Index of the newest run using each blob
+	for rows.Next() {
+		var run stored
+		var blob string
+		if err := rows.Scan(&run.id, &run.size, &blob, &run.protected); err != nil {
+			return 0, err
+		}
+		if blob != "" {
+			newest[blob] = len(runs)
+		}
+		total += run.size
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	// Runs sharing a blob only free it along with the last of them, so the
+	// blob is counted once, with the newest run using it
+	for blob, i := range newest {
+		if info, err := os.Stat(s.blobPath(blob)); err == nil {
+			runs[i].size += info.Size()
+			total += info.Size()
+		}
+	}
+
+	removed := 0
+	for _, run := range runs {
+		if total <= limit {
+			break
+		}
+		if run.protected {
+			continue
+		}
+		if _, err := s.conn.Exec(`DELETE FROM runs WHERE id = ?`, run.id); err != nil {
+			return removed, err
+		}
+		total -= run.size
+		removed++
+	}
+	return removed, nil
+}
+
+// removeUnusedBlobs deletes the blob files no run refers to and returns how many it removed
+func (s *Store) removeUnusedBlobs() (int, error) {
+	paths, err := filepath.Glob(filepath.Join(s.BlobDir(), "*.gz"))
+	if err != nil || len(paths) == 0 {
+		return 0, err
+	}
+
+	used := map[string]bool{}
+	rows, err := s.conn.Query(`SELECT DISTINCT blob FROM runs WHERE blob != ''`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return 0, err
+		}
+		used[hash] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		if used[strings.TrimSuffix(filepath.Base(path), ".gz")] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Size returns the size of the notebook database and of its blob files
+func (s *Store) Size() (int64, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	paths, err := filepath.Glob(filepath.Join(s.BlobDir(), "*.gz"))
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
+
+// Vacuum rebuilds the database file to give the space of removed rows back
+func (s *Store) Vacuum() error {
+	_, err := s.conn.Exec(`VACUUM`)
+	return err
+}
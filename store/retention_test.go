@@ -0,0 +1,184 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := &Store{}
+	if err := s.Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// randomOutput returns an output that compresses poorly, so its blob is large
+func randomOutput(t *testing.T, size int) string {
+	t.Helper()
+	data := make([]byte, size/2)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(data)
+}
+
+func addRuns(t *testing.T, s *Store, runs ...Run) {
+	t.Helper()
+	for _, run := range runs {
+		if _, err := s.AddRun(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func runIDs(t *testing.T, s *Store, commandID int64) map[int64]bool {
+	t.Helper()
+	runs, err := s.GetRunTimes(commandID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[int64]bool{}
+	for _, run := range runs {
+		ids[run.ID] = true
+	}
+	return ids
+}
+
+func TestPruneToSizeSharedBlob(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveCommand(Command{ID: 1, Command: "cat big"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two runs share the blob of the same large output, the latest run is kept anyway
+	output := randomOutput(t, 4*InlineOutputLimit)
+	start := time.Now().Add(-time.Hour)
+	addRuns(t, s,
+		Run{ID: 1, CommandID: 1, StartedAt: start, Output: output},
+		Run{ID: 2, CommandID: 1, StartedAt: start.Add(time.Minute), Output: output},
+		Run{ID: 3, CommandID: 1, StartedAt: start.Add(2 * time.Minute), Output: "small"},
+	)
+
+	// Removing the oldest run alone frees its preview but not the blob, so
+	// the limit is only met once the second run is removed too
+	limit := int64(InlineOutputLimit + InlineOutputLimit/2)
+	removed, err := s.pruneToSize(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed %d runs, want 2", removed)
+	}
+	if ids := runIDs(t, s, 1); len(ids) != 1 || !ids[3] {
+		t.Errorf("runs left %v, want only the latest one", ids)
+	}
+
+	blobs, err := s.removeUnusedBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blobs != 1 {
+		t.Errorf("removed %d blobs, want 1", blobs)
+	}
+}
+
+func TestRemoveUnusedBlobs(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveCommand(Command{ID: 1, Command: "cat big"}); err != nil {
+		t.Fatal(err)
+	}
+	addRuns(t, s, Run{ID: 1, CommandID: 1, StartedAt: time.Now(), Output: randomOutput(t, 2*InlineOutputLimit)})
+
+	orphan, err := s.writeBlob(randomOutput(t, 2*InlineOutputLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := s.removeUnusedBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("removed %d blobs, want 1", removed)
+	}
+	if _, err := os.Stat(s.blobPath(orphan)); !os.IsNotExist(err) {
+		t.Errorf("orphan blob still there: %v", err)
+	}
+
+	// The blob of the run is still readable
+	run, err := s.GetRun(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Output) != 2*InlineOutputLimit {
+		t.Errorf("output of %d bytes, want %d", len(run.Output), 2*InlineOutputLimit)
+	}
+}
+
+func TestPruneKeepRuns(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveCommand(Command{ID: 1, Command: "date"}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for i := int64(1); i <= 5; i++ {
+		addRuns(t, s, Run{ID: i, CommandID: 1, StartedAt: start.Add(time.Duration(i) * time.Minute), Output: "out"})
+	}
+	if err := s.SetGoldenRun(1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.Prune(Retention{KeepRuns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Runs != 2 {
+		t.Errorf("removed %d runs, want 2", result.Runs)
+	}
+	ids := runIDs(t, s, 1)
+	for _, id := range []int64{1, 4, 5} {
+		if !ids[id] {
+			t.Errorf("run %d removed, want the golden and the two latest runs kept", id)
+		}
+	}
+}
+
+func TestPruneDeletedCommand(t *testing.T) {
+	s := newTestStore(t)
+	for _, cmd := range []Command{{ID: 1, Command: "date"}, {ID: 2, Command: "uptime"}} {
+		if err := s.SaveCommand(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	addRuns(t, s,
+		Run{ID: 1, CommandID: 1, StartedAt: start, Output: randomOutput(t, 1024)},
+		Run{ID: 2, CommandID: 2, StartedAt: start.Add(time.Minute), Output: randomOutput(t, 1024)},
+	)
+	if err := s.DeleteCommand(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The latest run of the deleted command goes over the limit, the one of
+	// the command still there is kept anyway
+	removed, err := s.pruneToSize(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("removed %d runs, want 1", removed)
+	}
+	if ids := runIDs(t, s, 2); len(ids) != 0 {
+		t.Errorf("runs of the deleted command left %v, want none", ids)
+	}
+	if ids := runIDs(t, s, 1); !ids[1] {
+		t.Errorf("latest run of the command removed")
+	}
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Revision is a saved version of the text of a command
+type Revision struct {
+	ID        int64
+	CommandID int64
+	Command   string
+	CreatedAt time.Time
+}
+
+func addRevision(tx *sql.Tx, commandID int64, command string) error {
+	now := time.Now().UTC().UnixNano()
+	query := `INSERT INTO revisions (id, command_id, command, created_at) VALUES (?, ?, ?, ?)`
+	_, err := tx.Exec(query, now, commandID, command, now)
+	return err
+}
+
+// GetRevisions returns the saved versions of a command, most recent first
+func (s *Store) GetRevisions(commandID int64) ([]Revision, error) {
+	rows, err := s.conn.Query(`SELECT id, command_id, command, created_at FROM revisions
+		WHERE command_id = ? ORDER BY created_at DESC, id DESC`, commandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var revision Revision
+		var createdAt int64
+		if err := rows.Scan(&revision.ID, &revision.CommandID, &revision.Command, &createdAt); err != nil {
+			return nil, err
+		}
+		revision.CreatedAt = time.Unix(0, createdAt)
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Chunk is a piece of a run output written to one of the command streams
+type Chunk struct {
+	Stream string
+	Time   time.Time
+	Text   string
+}
+
+// segment locates a chunk in the run output, which is how chunks are stored
+type segment struct {
+	Stream string `json:"stream"`
+	Time   int64  `json:"time"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// Run is a single execution of a command along with its captured output
+type Run struct {
+	ID        int64
+	CommandID int64
+	StartedAt time.Time
+	Duration  time.Duration
+	ExitCode  int
+	Output    string
+	Chunks    []Chunk // Output split by stream in the order it was written
+}
+
+func (s *Store) AddRun(run Run) (int64, error) {
+	if run.ID == 0 {
+		run.ID = time.Now().UTC().UnixNano()
+	}
+
+	segments, err := encodeChunks(run.Output, run.Chunks)
+	if err != nil {
+		return 0, err
+	}
+
+	// Large outputs go to a blob file, the database keeps their beginning
+	output, blob := run.Output, ""
+	if len(output) > InlineOutputLimit {
+		if blob, err = s.writeBlob(output); err != nil {
+			return 0, err
+		}
+		output = preview(output)
+	}
+
+	query := `INSERT INTO runs (id, command_id, started_at, duration, exit_code, output, segments, blob)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = s.conn.Exec(query, run.ID, run.CommandID, run.StartedAt.UnixNano(),
+		int64(run.Duration), run.ExitCode, output, segments, blob)
+	if err != nil {
+		return 0, err
+	}
+
+	retention, err := s.GetRetention()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.pruneCommandRuns(run.CommandID, retention.KeepRuns); err != nil {
+		return 0, err
+	}
+
+	return run.ID, nil
+}
+
+// GetRuns returns the runs of a command, most recent first
+func (s *Store) GetRuns(commandID int64) ([]Run, error) {
+	rows, err := s.conn.Query(`SELECT id, command_id, started_at, duration, exit_code, output, segments, blob
+		FROM runs WHERE command_id = ? ORDER BY started_at DESC`, commandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		run, err := s.scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// GetRunTimes returns the latest runs of a command without their outputs, most
+// recent first
+func (s *Store) GetRunTimes(commandID int64, limit int) ([]Run, error) {
+	rows, err := s.conn.Query(`SELECT id, command_id, started_at, duration, exit_code
+		FROM runs WHERE command_id = ? ORDER BY started_at DESC LIMIT ?`, commandID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		var run Run
+		var startedAt, duration int64
+		if err := rows.Scan(&run.ID, &run.CommandID, &startedAt, &duration, &run.ExitCode); err != nil {
+			return nil, err
+		}
+		run.StartedAt = time.Unix(0, startedAt)
+		run.Duration = time.Duration(duration)
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// GetLastRun returns the most recent run of a command, or sql.ErrNoRows if it never ran
+func (s *Store) GetLastRun(commandID int64) (Run, error) {
+	row := s.conn.QueryRow(`SELECT id, command_id, started_at, duration, exit_code, output, segments, blob
+		FROM runs WHERE command_id = ? ORDER BY started_at DESC LIMIT 1`, commandID)
+	return s.scanRun(row)
+}
+
+// GetRun returns a run by ID, or sql.ErrNoRows if it does not exist
+func (s *Store) GetRun(id int64) (Run, error) {
+	row := s.conn.QueryRow(`SELECT id, command_id, started_at, duration, exit_code, output, segments, blob
+		FROM runs WHERE id = ?`, id)
+	return s.scanRun(row)
+}
+
+// SetGoldenRun pins the run other runs of the command are compared to by
+// default. A zero run ID unpins it.
+func (s *Store) SetGoldenRun(commandID, runID int64) error {
+	_, err := s.conn.Exec(`UPDATE commands SET golden_run_id = ? WHERE id = ?`, runID, commandID)
+	return err
+}
+
+// GetGoldenRunID returns the ID of the pinned run of a command, or zero
+func (s *Store) GetGoldenRunID(commandID int64) (int64, error) {
+	var runID int64
+	err := s.conn.QueryRow(`SELECT golden_run_id FROM commands WHERE id = ?`, commandID).Scan(&runID)
+	return runID, err
+}
+
+// GetLastRuns returns the most recent run of every command that ran, by command ID
+func (s *Store) GetLastRuns() (map[int64]Run, error) {
+	rows, err := s.conn.Query(`SELECT id, command_id, started_at, duration, exit_code, output, segments, blob
+		FROM runs WHERE started_at = (
+			SELECT max(started_at) FROM runs AS latest WHERE latest.command_id = runs.command_id
+		)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := map[int64]Run{}
+	for rows.Next() {
+		run, err := s.scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs[run.CommandID] = run
+	}
+
+	return runs, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (s *Store) scanRun(row scanner) (Run, error) {
+	var run Run
+	var startedAt, duration int64
+	var segments, blob string
+	if err := row.Scan(&run.ID, &run.CommandID, &startedAt, &duration, &run.ExitCode, &run.Output, &segments, &blob); err != nil {
+		return Run{}, err
+	}
+	if blob != "" {
+		// A lost blob leaves the beginning of the output kept in the database
+		if output, err := s.readBlob(blob); err == nil {
+			run.Output = output
+		} else {
+			run.Output += "\n… rest of the output lost: " + err.Error()
+		}
+	}
+	run.StartedAt = time.Unix(0, startedAt)
+	run.Duration = time.Duration(duration)
+	run.Chunks = decodeChunks(run.Output, segments, run.StartedAt)
+	return run, nil
+}
+
+// encodeChunks stores the chunks as segments of the output, so their text is
+// not kept twice. Chunks that do not add up to the output are dropped.
+func encodeChunks(output string, chunks []Chunk) (string, error) {
+	segments := []segment{}
+	offset := 0
+	for _, chunk := range chunks {
+		if offset+len(chunk.Text) > len(output) || output[offset:offset+len(chunk.Text)] != chunk.Text {
+			return "", nil
+		}
+		segments = append(segments, segment{chunk.Stream, chunk.Time.UnixNano(), offset, len(chunk.Text)})
+		offset += len(chunk.Text)
+	}
+	if offset != len(output) || len(segments) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(segments)
+	return string(data), err
+}
+
+// decodeChunks splits the output along its segments. Runs recorded before
+// streams were separated are a single stdout chunk.
+func decodeChunks(output, encoded string, startedAt time.Time) []Chunk {
+	if output == "" {
+		return nil
+	}
+
+	var segments []segment
+	if encoded == "" || json.Unmarshal([]byte(encoded), &segments) != nil {
+		return []Chunk{{Stream: StreamStdout, Time: startedAt, Text: output}}
+	}
+
+	chunks := make([]Chunk, 0, len(segments))
+	for _, seg := range segments {
+		if seg.Offset < 0 || seg.Offset+seg.Length > len(output) {
+			return []Chunk{{Stream: StreamStdout, Time: startedAt, Text: output}}
+		}
+		chunks = append(chunks, Chunk{seg.Stream, time.Unix(0, seg.Time), output[seg.Offset : seg.Offset+seg.Length]})
+	}
+	return chunks
+}
+
+// Stream returns the part of the output written to a stream, or the whole
+// output for an empty stream
+func (r Run) Stream(stream string) string {
+	if stream == "" {
+		return r.Output
+	}
+	var b strings.Builder
+	for _, chunk := range r.Chunks {
+		if chunk.Stream == stream {
+			b.WriteString(chunk.Text)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// runAllSetting holds the summary of the last time every cell ran
+const runAllSetting = "run_all"
+
+// RunAll summarizes a run of every cell of the notebook, one after the other
+type RunAll struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"` // From the start of the first cell to the end of the last one
+	Cells     int           `json:"cells"`
+	Failed    int           `json:"failed"`
+}
+
+// GetLastRunAll returns the last run of every cell, and false if there was none
+func (s *Store) GetLastRunAll() (RunAll, bool, error) {
+	var runAll RunAll
+	value, err := s.GetSetting(runAllSetting)
+	if err != nil || value == "" {
+		return runAll, false, err
+	}
+	if err := json.Unmarshal([]byte(value), &runAll); err != nil {
+		return runAll, false, err
+	}
+	return runAll, true, nil
+}
+
+// SetLastRunAll records a run of every cell
+func (s *Store) SetLastRunAll(runAll RunAll) error {
+	data, err := json.Marshal(runAll)
+	if err != nil {
+		return err
+	}
+	return s.SetSetting(runAllSetting, string(data))
+}
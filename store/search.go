@@ -0,0 +1,193 @@
+package store
+
+import (
+	"strings"
+)
+
+const (
+	SourceCommand = "command"
+	SourceOutput  = "output"
+)
+
+var searchTriggers = []string{
+	"commands_search_insert",
+	"commands_search_update",
+	"commands_search_delete",
+	"runs_search_insert",
+	"runs_search_delete",
+}
+
+// SearchResult is a command or run output matching a search query
+type SearchResult struct {
+	CommandID int64
+	RunID     int64  // Set when the match comes from a run output
+	Source    string // SourceCommand or SourceOutput
+	Text      string // Full text of the matched command or output
+}
+
+// initSearchIndex creates the FTS5 index over command text and run outputs.
+// go-sqlite3 only ships FTS5 when built with the sqlite_fts5 tag, as the
+// Makefile does, so when the module is missing the store falls back to plain
+// LIKE queries, see FullText.
+func (s *Store) initSearchIndex() error {
+	_, err := s.conn.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		body, source UNINDEXED, command_id UNINDEXED, run_id UNINDEXED
+	);`)
+	if err == nil {
+		// The table may predate this build, make sure the module can read it
+		_, err = s.conn.Exec(`SELECT count(*) FROM search_index`)
+	}
+	if err != nil {
+		if !strings.Contains(err.Error(), "no such module") {
+			return err
+		}
+		// Without the module the triggers would make every write fail
+		for _, trigger := range searchTriggers {
+			if _, err := s.conn.Exec(`DROP TRIGGER IF EXISTS ` + trigger); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// When the triggers are missing the index is either new or was left
+	// behind by a build without FTS5, so rebuild it from scratch
+	var triggers int
+	err = s.conn.QueryRow(`SELECT count(*) FROM sqlite_master
+		WHERE type = 'trigger' AND name LIKE '%_search_%'`).Scan(&triggers)
+	if err != nil {
+		return err
+	}
+
+	queries := []string{
+		`CREATE TRIGGER IF NOT EXISTS commands_search_insert AFTER INSERT ON commands BEGIN
+			INSERT INTO search_index (body, source, command_id, run_id)
+			VALUES (new.command, 'command', new.id, 0);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS commands_search_update AFTER UPDATE OF command ON commands BEGIN
+			DELETE FROM search_index WHERE source = 'command' AND command_id = old.id;
+			INSERT INTO search_index (body, source, command_id, run_id)
+			VALUES (new.command, 'command', new.id, 0);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS commands_search_delete AFTER DELETE ON commands BEGIN
+			DELETE FROM search_index WHERE source = 'command' AND command_id = old.id;
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS runs_search_insert AFTER INSERT ON runs BEGIN
+			INSERT INTO search_index (body, source, command_id, run_id)
+			VALUES (new.output, 'output', new.command_id, new.id);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS runs_search_delete AFTER DELETE ON runs BEGIN
+			DELETE FROM search_index WHERE source = 'output' AND run_id = old.id;
+		END;`,
+	}
+
+	if triggers < len(searchTriggers) {
+		queries = append(queries,
+			`DELETE FROM search_index;`,
+			`INSERT INTO search_index (body, source, command_id, run_id)
+			SELECT command, 'command', id, 0 FROM commands;`,
+			`INSERT INTO search_index (body, source, command_id, run_id)
+			SELECT output, 'output', command_id, id FROM runs;`,
+		)
+	}
+
+	for _, query := range queries {
+		if _, err = s.conn.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	s.fts = true
+	return nil
+}
+
+// FullText reports whether search uses the FTS5 index, or falls back to LIKE
+// queries in a build without the sqlite_fts5 tag
+func (s *Store) FullText() bool {
+	return s.fts
+}
+
+// Search returns the commands whose text or stored output contains every term
+// of the query. Each command appears at most once per source, and output
+// matches come from the most recent matching run.
+func (s *Store) Search(query string) ([]SearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	var (
+		sqlQuery string
+		args     []any
+	)
+	if s.fts {
+		// Quote every term so that user input is never parsed as FTS5 syntax,
+		// and allow prefix matches so that "kube" finds "kubectl"
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+		}
+		sqlQuery = `SELECT command_id, run_id, source, body FROM search_index
+			WHERE search_index MATCH ? AND command_id IN (SELECT id FROM commands)
+			ORDER BY run_id DESC`
+		args = []any{strings.Join(quoted, " ")}
+	} else {
+		conditions := make([]string, len(terms))
+		for i, term := range terms {
+			conditions[i] = `body LIKE ? ESCAPE '\'`
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+		sqlQuery = `SELECT command_id, run_id, source, body FROM (
+				SELECT id AS command_id, 0 AS run_id, 'command' AS source, command AS body FROM commands
+				UNION ALL
+				SELECT command_id, id, 'output', output FROM runs
+				WHERE command_id IN (SELECT id FROM commands)
+			) WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY run_id DESC`
+	}
+
+	rows, err := s.conn.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type key struct {
+		commandID int64
+		source    string
+	}
+	seen := map[key]bool{}
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		if err := rows.Scan(&result.CommandID, &result.RunID, &result.Source, &result.Text); err != nil {
+			return nil, err
+		}
+		k := key{result.CommandID, result.Source}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// MatchingLine returns the first line of text containing one of the terms,
+// ignoring case, or the first line if none does
+func MatchingLine(text string, terms []string) string {
+	lines := strings.Split(text, "\n")
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		for _, term := range terms {
+			if strings.Contains(lower, strings.ToLower(term)) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	return strings.TrimSpace(lines[0])
+}
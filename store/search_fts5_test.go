@@ -0,0 +1,12 @@
+//go:build sqlite_fts5
+
+package store
+
+import "testing"
+
+func TestFullText(t *testing.T) {
+	s := newTestStore(t)
+	if !s.FullText() {
+		t.Error("built with sqlite_fts5 but search falls back to LIKE queries")
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	s := newTestStore(t)
+	for _, cmd := range []Command{
+		{ID: 1, Command: "kubectl get pods"},
+		{ID: 2, Command: "docker ps"},
+		{ID: 3, Command: "echo done"},
+	} {
+		if err := s.SaveCommand(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	addRuns(t, s,
+		// The older output is shorter, so it ranks better in the full-text index
+		Run{ID: 1, CommandID: 2, StartedAt: start, Output: "IMAGE\npostgres"},
+		Run{ID: 2, CommandID: 2, StartedAt: start.Add(time.Minute), Output: "CONTAINER ID   IMAGE   PORTS\ndef456   postgres   5432/tcp"},
+	)
+
+	// Deleted commands keep their runs, to be undone, but are not found
+	if err := s.SaveCommand(Command{ID: 4, Command: "psql -c 'select 1'"}); err != nil {
+		t.Fatal(err)
+	}
+	addRuns(t, s, Run{ID: 3, CommandID: 4, StartedAt: start, Output: "postgres kubectl"})
+	if err := s.DeleteCommand(4); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string // Source and command ID of every result, in any order
+	}{
+		{query: "kubectl", want: []string{"command 1"}},
+		{query: "postgres", want: []string{"output 2"}},
+		{query: "docker ps", want: []string{"command 2"}},
+		{query: "get pods", want: []string{"command 1"}},
+		{query: "missing", want: nil},
+		{query: "  ", want: nil},
+		{query: `"quoted`, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := s.Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]bool{}
+			for _, result := range results {
+				got[fmt.Sprintf("%s %d", result.Source, result.CommandID)] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for _, want := range tt.want {
+				if !got[want] {
+					t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+
+	// Output matches come from the most recent matching run
+	results, err := s.Search("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].RunID != 2 {
+		t.Errorf("Search(postgres) = %+v, want the match of run 2", results)
+	}
+}
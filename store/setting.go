@@ -0,0 +1,23 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// GetSetting returns the value of a notebook setting, or an empty string
+func (s *Store) GetSetting(key string) (string, error) {
+	var value string
+	err := s.conn.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetSetting changes the value of a notebook setting
+func (s *Store) SetSetting(key, value string) error {
+	_, err := s.conn.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
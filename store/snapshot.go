@@ -0,0 +1,91 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Snapshot is the accepted result of a command, compared against by cahier test
+type Snapshot struct {
+	CommandID int64
+	Output    string
+	ExitCode  int
+	UpdatedAt time.Time
+}
+
+// GetSnapshot returns the snapshot of a command and whether it has one
+func (s *Store) GetSnapshot(commandID int64) (Snapshot, bool, error) {
+	snapshot := Snapshot{CommandID: commandID}
+	var updatedAt int64
+	err := s.conn.QueryRow(`SELECT output, exit_code, updated_at FROM snapshots WHERE command_id = ?`, commandID).
+		Scan(&snapshot.Output, &snapshot.ExitCode, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	snapshot.UpdatedAt = time.Unix(0, updatedAt)
+	return snapshot, true, nil
+}
+
+// SaveSnapshot records or replaces the snapshot of a command
+func (s *Store) SaveSnapshot(snapshot Snapshot) error {
+	if snapshot.UpdatedAt.IsZero() {
+		snapshot.UpdatedAt = time.Now()
+	}
+
+	query := `INSERT INTO snapshots (command_id, output, exit_code, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(command_id) DO UPDATE
+		SET output=excluded.output,
+		    exit_code=excluded.exit_code,
+		    updated_at=excluded.updated_at;`
+
+	_, err := s.conn.Exec(query, snapshot.CommandID, snapshot.Output, snapshot.ExitCode, snapshot.UpdatedAt.UnixNano())
+	return err
+}
+
+// SetNormalizers replaces the normalizers applied to the output of a command
+// before comparing it to its snapshot
+func (s *Store) SetNormalizers(commandID int64, normalizers []string) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM normalizers WHERE command_id = ?`, commandID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO normalizers (command_id, position, spec) VALUES (?, ?, ?)`
+	for i, spec := range normalizers {
+		if _, err := tx.Exec(query, commandID, i, spec); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetNormalizers returns the normalizers of a command in the order they apply
+func (s *Store) GetNormalizers(commandID int64) ([]string, error) {
+	rows, err := s.conn.Query(`SELECT spec FROM normalizers WHERE command_id = ? ORDER BY position`, commandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	normalizers := []string{}
+	for rows.Next() {
+		var spec string
+		if err := rows.Scan(&spec); err != nil {
+			return nil, err
+		}
+		normalizers = append(normalizers, spec)
+	}
+
+	return normalizers, rows.Err()
+}
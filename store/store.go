@@ -2,6 +2,8 @@ package store
 
 import (
 	"database/sql"
+	"errors"
+	"net/url"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -9,31 +11,237 @@ import (
 
 type Store struct {
 	conn *sql.DB
+	path string
+	fts  bool // Whether the FTS5 search index is available
 }
 
 func (s *Store) Init(dbPath string) error {
 	var err error
+	s.path = dbPath
 	s.conn, err = sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return err
 	}
 
-	query := `CREATE TABLE IF NOT EXISTS commands (
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS commands (
 		id integer not null primary key,
 		command text not null,
 		status text default '',
-		return_code integer default 0
-	);`
+		return_code integer default 0,
+		position integer,
+		golden_run_id integer default 0,
+		status_detail text default ''
+	);`,
+		`CREATE TABLE IF NOT EXISTS runs (
+		id integer not null primary key,
+		command_id integer not null,
+		started_at integer not null,
+		duration integer default 0,
+		exit_code integer default 0,
+		output text default '',
+		segments text default '',
+		blob text default ''
+	);`,
+		`CREATE INDEX IF NOT EXISTS runs_command_id ON runs (command_id);`,
+		`CREATE TABLE IF NOT EXISTS tags (
+		id integer not null primary key,
+		name text not null unique
+	);`,
+		`CREATE TABLE IF NOT EXISTS command_tags (
+		command_id integer not null,
+		tag_id integer not null,
+		primary key (command_id, tag_id)
+	);`,
+		`CREATE TABLE IF NOT EXISTS operations (
+		id integer not null primary key autoincrement,
+		kind text not null,
+		command_id integer not null,
+		before text not null,
+		after text not null,
+		undone integer default 0,
+		created_at integer not null
+	);`,
+		`CREATE TABLE IF NOT EXISTS revisions (
+		id integer not null primary key,
+		command_id integer not null,
+		command text not null,
+		created_at integer not null
+	);`,
+		`CREATE INDEX IF NOT EXISTS revisions_command_id ON revisions (command_id);`,
+		`CREATE TABLE IF NOT EXISTS assertions (
+		command_id integer not null,
+		position integer not null,
+		kind text not null,
+		path text default '',
+		value text default '',
+		primary key (command_id, position)
+	);`,
+		`CREATE TABLE IF NOT EXISTS snapshots (
+		command_id integer not null primary key,
+		output text not null,
+		exit_code integer default 0,
+		updated_at integer not null
+	);`,
+		`CREATE TABLE IF NOT EXISTS normalizers (
+		command_id integer not null,
+		position integer not null,
+		spec text not null,
+		primary key (command_id, position)
+	);`,
+		`CREATE TABLE IF NOT EXISTS audit (
+		id integer not null primary key autoincrement,
+		created_at integer not null,
+		kind text not null,
+		command_id integer not null,
+		run_id integer default 0,
+		command text not null,
+		user text not null,
+		host text not null,
+		dir text default '',
+		profile text default '',
+		detail text default '',
+		prev_hash text default '',
+		hash text default ''
+	);`,
+		`CREATE TABLE IF NOT EXISTS settings (
+		key text not null primary key,
+		value text not null
+	);`,
+	}
+
+	for _, query := range queries {
+		if _, err = s.conn.Exec(query); err != nil {
+			return err
+		}
+	}
 
+	// Notebooks created before commands could be reordered keep their
+	// insertion order, which follows the time based IDs
+	if err = s.addColumn("commands", "position", "integer"); err != nil {
+		return err
+	}
+	if _, err = s.conn.Exec(`UPDATE commands SET position = id WHERE position IS NULL`); err != nil {
+		return err
+	}
+	if err = s.addColumn("commands", "golden_run_id", "integer default 0"); err != nil {
+		return err
+	}
+	if err = s.addColumn("commands", "status_detail", "text default ''"); err != nil {
+		return err
+	}
+	if err = s.addColumn("runs", "segments", "text default ''"); err != nil {
+		return err
+	}
+	if err = s.addColumn("runs", "blob", "text default ''"); err != nil {
+		return err
+	}
+
+	// Commands saved before revisions were kept start with their current
+	// text, dated from their time based ID
+	query := `INSERT INTO revisions (id, command_id, command, created_at)
+		SELECT id, id, command, id FROM commands
+		WHERE id NOT IN (SELECT command_id FROM revisions)`
 	if _, err = s.conn.Exec(query); err != nil {
 		return err
 	}
 
+	if err = s.initAudit(); err != nil {
+		return err
+	}
+
+	return s.initSearchIndex()
+}
+
+// addColumn adds a column to a table created by an older version, unless it is already there
+func (s *Store) addColumn(table, column, definition string) error {
+	found, err := s.hasColumn(table, column)
+	if err != nil || found {
+		return err
+	}
+	_, err = s.conn.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
+
+// hasColumn reports whether a table has a column, false when the table is missing
+func (s *Store) hasColumn(table, column string) (bool, error) {
+	rows, err := s.conn.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// ErrOldSchema tells that a notebook opened read-only was written by an older
+// version, which only Init upgrades
+var ErrOldSchema = errors.New("the notebook was written by an older version of cahier, open it once to upgrade it")
+
+// readColumns are the newest columns the commands and runs are read from
+var readColumns = [][2]string{
+	{"commands", "position"},
+	{"commands", "status_detail"},
+	{"command_tags", "tag_id"},
+	{"runs", "segments"},
+	{"runs", "blob"},
+}
+
+// OpenReadOnly opens a notebook to read its commands and runs without
+// writing to it: nothing is created or migrated, and a notebook written by an
+// older version fails with ErrOldSchema. Search falls back to LIKE queries.
+func (s *Store) OpenReadOnly(dbPath string) error {
+	var err error
+	s.path = dbPath
+	dsn := (&url.URL{Scheme: "file", Path: dbPath, RawQuery: "mode=ro"}).String()
+	if s.conn, err = sql.Open("sqlite3", dsn); err != nil {
+		return err
+	}
+
+	for _, column := range readColumns {
+		found, err := s.hasColumn(column[0], column[1])
+		if err == nil && !found {
+			err = ErrOldSchema
+		}
+		if err != nil {
+			s.conn.Close()
+			return err
+		}
+	}
 	return nil
 }
 
+func (s *Store) Close() error {
+	return s.conn.Close()
+}
+
+// Path returns the path of the notebook database
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Name returns the notebook name, which is the database file name without extension
+func (s *Store) Name() string {
+	return NotebookName(s.path)
+}
+
 func (s *Store) GetCommands() ([]Command, error) {
-	rows, err := s.conn.Query("SELECT id, command, status, return_code FROM commands")
+	tags, err := s.getTags()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.conn.Query(`SELECT id, command, status, return_code, status_detail
+		FROM commands ORDER BY position, id`)
 	if err != nil {
 		return nil, err
 	}
@@ -42,38 +250,83 @@ func (s *Store) GetCommands() ([]Command, error) {
 	cmds := []Command{}
 	for rows.Next() {
 		var cmd Command
-		rows.Scan(&cmd.ID, &cmd.Command, &cmd.Status, &cmd.ReturnCode)
+		rows.Scan(&cmd.ID, &cmd.Command, &cmd.Status, &cmd.ReturnCode, &cmd.StatusDetail)
+		cmd.Tags = tags[cmd.ID]
 		cmds = append(cmds, cmd)
 	}
 
 	return cmds, nil
 }
 
+// SaveCommand inserts a new command at the end of the notebook, or updates the
+// text of an existing one, and records the change in the operation log
 func (s *Store) SaveCommand(cmd Command) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, found, err := getSnapshot(tx, cmd.ID)
+	if err != nil {
+		return err
+	}
+
 	if cmd.ID == 0 {
 		cmd.ID = time.Now().UTC().UnixNano()
 	}
 
-	query := `INSERT INTO commands (id, command, status, return_code)
-		VALUES (?, ?, ?, ?)
+	position := before.Position
+	if !found {
+		if err := tx.QueryRow(`SELECT coalesce(max(position), 0) + 1 FROM commands`).Scan(&position); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO commands (id, command, status, return_code, position)
+		VALUES (?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE
 		SET command=excluded.command,
 		    status=excluded.status,
 		    return_code=excluded.return_code;`
 
-	if _, err := s.conn.Exec(query, cmd.ID, cmd.Command, cmd.Status, cmd.ReturnCode); err != nil {
+	if _, err := tx.Exec(query, cmd.ID, cmd.Command, cmd.Status, cmd.ReturnCode, position); err != nil {
 		return err
 	}
 
-	return nil
+	after := snapshot{ID: cmd.ID, Command: cmd.Command, Status: cmd.Status, ReturnCode: cmd.ReturnCode, Position: position}
+	switch {
+	case !found:
+		err = logOperation(tx, OpInsert, cmd.ID, nil, []snapshot{after})
+	case before.Command != cmd.Command:
+		err = logOperation(tx, OpEdit, cmd.ID, []snapshot{before}, []snapshot{after})
+	}
+	if err != nil {
+		return err
+	}
+
+	if !found || before.Command != cmd.Command {
+		if err := addRevision(tx, cmd.ID, cmd.Command); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
+// UpdateCommandStatus sets the status of a command, clearing its status detail
 func (s *Store) UpdateCommandStatus(id int64, status string, returnCode int) error {
-	query := `UPDATE commands SET status = ?, return_code = ? WHERE id = ?`
-	
+	query := `UPDATE commands SET status = ?, return_code = ?, status_detail = '' WHERE id = ?`
+
 	if _, err := s.conn.Exec(query, status, returnCode, id); err != nil {
 		return err
 	}
-	
+
 	return nil
 }
+
+// SetStatusDetail explains the status of a command, such as the assertions it failed
+func (s *Store) SetStatusDetail(id int64, detail string) error {
+	_, err := s.conn.Exec(`UPDATE commands SET status_detail = ? WHERE id = ?`, detail, id)
+	return err
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		alter   string // Query run on the notebook before opening it read-only
+		missing bool   // Open a path without a notebook
+		wantErr error
+	}{
+		{name: "current schema"},
+		{name: "old commands", alter: `ALTER TABLE commands DROP COLUMN status_detail`, wantErr: ErrOldSchema},
+		{name: "old runs", alter: `ALTER TABLE runs DROP COLUMN segments`, wantErr: ErrOldSchema},
+		{name: "without tags", alter: `DROP TABLE command_tags`, wantErr: ErrOldSchema},
+		{name: "missing", missing: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.db")
+			if !tt.missing {
+				s := &Store{}
+				if err := s.Init(path); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.SaveCommand(Command{ID: 1, Command: "date"}); err != nil {
+					t.Fatal(err)
+				}
+				if tt.alter != "" {
+					if _, err := s.conn.Exec(tt.alter); err != nil {
+						t.Fatal(err)
+					}
+				}
+				s.Close()
+			}
+			before, _ := os.ReadFile(path)
+
+			s := &Store{}
+			err := s.OpenReadOnly(path)
+			switch {
+			case tt.missing:
+				if err == nil {
+					t.Fatal("opened a missing notebook")
+				}
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Error("created the missing notebook")
+				}
+				return
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatal(err)
+			default:
+				cmds, err := s.GetCommands()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(cmds) != 1 || cmds[0].Command != "date" {
+					t.Errorf("got commands %+v", cmds)
+				}
+				if _, err := s.GetLastRuns(); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.SaveCommand(Command{ID: 2, Command: "ls"}); err == nil {
+					t.Error("saved a command to a read-only notebook")
+				}
+				s.Close()
+			}
+
+			if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+				t.Error("the notebook changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"strings"
+)
+
+// NormalizeTag strips the leading '#' users type in front of tag names
+func NormalizeTag(tag string) string {
+	return strings.TrimLeft(strings.TrimSpace(tag), "#")
+}
+
+// SetTags replaces the tags of a command
+func (s *Store) SetTags(commandID int64, tags []string) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM command_tags WHERE command_id = ?`, commandID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+			return err
+		}
+		query := `INSERT OR IGNORE INTO command_tags (command_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?`
+		if _, err := tx.Exec(query, commandID, tag); err != nil {
+			return err
+		}
+	}
+
+	// Forget the tags no command uses anymore
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM command_tags)`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// getTags returns the tags of every tagged command, sorted by name
+func (s *Store) getTags() (map[int64][]string, error) {
+	rows, err := s.conn.Query(`SELECT command_tags.command_id, tags.name
+		FROM command_tags JOIN tags ON tags.id = command_tags.tag_id
+		ORDER BY tags.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := map[int64][]string{}
+	for rows.Next() {
+		var commandID int64
+		var name string
+		if err := rows.Scan(&commandID, &name); err != nil {
+			return nil, err
+		}
+		tags[commandID] = append(tags[commandID], name)
+	}
+
+	return tags, rows.Err()
+}
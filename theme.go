@@ -0,0 +1,56 @@
+package main
+
+import (
+	"cahier/diff"
+	"cahier/highlight"
+	"cahier/history"
+	"cahier/inspector"
+	"cahier/pager"
+	"cahier/palette"
+	"cahier/theme"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// applyTheme colors the whole interface with a theme
+func applyTheme(t theme.Theme) {
+	history.SetTheme(t)
+	pager.SetTheme(t)
+	palette.SetTheme(t)
+	diff.SetTheme(t)
+	highlight.SetTheme(t)
+	inspector.SetTheme(t)
+
+	accent := lipgloss.Color(t.Accent)
+	appNameStyle = appNameStyle.Background(lipgloss.Color(t.Title)).Reverse(t.Title == "")
+	faintStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(t.Footer)).Faint(t.Faint)
+	errorStyle = errorStyle.Foreground(lipgloss.Color(t.Error))
+	textareaStyle = textareaStyle.BorderForeground(lipgloss.Color(t.Border))
+	textareaLabelStyle = textareaLabelStyle.Foreground(accent)
+	confirmTitleStyle = confirmTitleStyle.Foreground(lipgloss.Color(t.Error))
+	confirmBoxStyle = confirmBoxStyle.BorderForeground(lipgloss.Color(t.Error))
+	selectedRevisionStyle = selectedRevisionStyle.Foreground(lipgloss.Color(t.Info))
+	diffBoxStyle = diffBoxStyle.BorderForeground(lipgloss.Color(t.Border))
+	helpTitleStyle = helpTitleStyle.Foreground(accent)
+	helpKeyStyle = helpKeyStyle.Foreground(lipgloss.Color(t.Info))
+	passedStyle = passedStyle.Foreground(lipgloss.Color(t.Success))
+	failedStyle = failedStyle.Foreground(lipgloss.Color(t.Error))
+	runningStyle = runningStyle.Foreground(lipgloss.Color(t.Info))
+}
+
+// loadTheme applies the theme of the current notebook
+func loadTheme(m Model) (Model, error) {
+	t, err := m.settings().LoadTheme()
+	if err != nil {
+		return m, err
+	}
+	applyTheme(t)
+	m.theme = t
+	m.cmdsHistory.Refresh()
+	return m, nil
+}
+
+// animates reports whether the selected cell border of the current notebook cycles colors
+func animates(m Model) bool {
+	return m.settings().Animation && m.theme.Animated()
+}
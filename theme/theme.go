@@ -0,0 +1,253 @@
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme holds the colors of the interface. A color is a hex value such as
+// "#B19CD9", an ANSI color number from 0 to 255, or empty for the default color
+// of the terminal.
+type Theme struct {
+	Base      string   `toml:"base"`      // Built-in theme a theme file starts from, dark by default
+	Accent    string   `toml:"accent"`    // Cell numbers, labels and tags
+	Title     string   `toml:"title"`     // Background of the app name
+	Border    string   `toml:"border"`    // Border of the cells and boxes
+	Selected  []string `toml:"selected"`  // Border of the selected cell, cycled through when animated
+	Text      string   `toml:"text"`      // Text on a colored background
+	Highlight string   `toml:"highlight"` // Background of search matches, reversed when empty
+	Footer    string   `toml:"footer"`
+	Faint     bool     `toml:"faint"`  // Whether the footer and hints are dimmed
+	Output    string   `toml:"output"` // Command outputs
+	Muted     string   `toml:"muted"`  // Rules and hints around outputs
+	Success   string   `toml:"success"`
+	Warning   string   `toml:"warning"`
+	Error     string   `toml:"error"` // Failures, stderr and errors
+	Info      string   `toml:"info"`  // Selected items in lists
+	Syntax    Syntax   `toml:"syntax"`
+}
+
+// Syntax holds the colors of shell commands
+type Syntax struct {
+	Keyword  string `toml:"keyword"`
+	String   string `toml:"string"`
+	Variable string `toml:"variable"`
+	Operator string `toml:"operator"`
+	Comment  string `toml:"comment"`
+}
+
+var builtins = map[string]Theme{
+	"dark": {
+		Accent: "#B19CD9",
+		Title:  "99",
+		Border: "#E8E8E8",
+		Selected: []string{
+			"#FFB3BA", "#FFC7B3", "#FFDAB3", "#FFEDB3", "#FFFFB3", "#D7FFB3",
+			"#BAFFB3", "#B3FFD7", "#B3FFFF", "#B3E5FF", "#B3CCFF", "#B3BAFF",
+			"#C7B3FF", "#D3B3FF", "#E0B3FF", "#EDB3FF", "#FFB3F0", "#FFB3D7",
+		},
+		Text:      "#1A1A1A",
+		Highlight: "#FFEDB3",
+		Footer:    "255",
+		Faint:     true,
+		Output:    "#D0D0D0",
+		Muted:     "#6C6C6C",
+		Success:   "#BAFFB3",
+		Warning:   "#FFDAB3",
+		Error:     "#FFB3BA",
+		Info:      "#B3E5FF",
+		Syntax: Syntax{
+			Keyword:  "#B19CD9",
+			String:   "#BAFFB3",
+			Variable: "#B3E5FF",
+			Operator: "#FFDAB3",
+			Comment:  "#8A8A8A",
+		},
+	},
+	"light": {
+		Accent: "#6A4C93",
+		Title:  "183",
+		Border: "#8A8A8A",
+		Selected: []string{
+			"#C0392B", "#CA6F1E", "#B7950B", "#28B463", "#17A589",
+			"#2E86C1", "#2874A6", "#6C3483", "#884EA0", "#A93226",
+		},
+		Text:      "#FFFFFF",
+		Highlight: "#9A7D0A",
+		Footer:    "236",
+		Faint:     true,
+		Output:    "#303030",
+		Muted:     "#8A8A8A",
+		Success:   "#1E8449",
+		Warning:   "#AF601A",
+		Error:     "#B03A2E",
+		Info:      "#1F618D",
+		Syntax: Syntax{
+			Keyword:  "#6A4C93",
+			String:   "#1E8449",
+			Variable: "#1F618D",
+			Operator: "#AF601A",
+			Comment:  "#7B7D7D",
+		},
+	},
+	"high-contrast": {
+		Accent:    "11",
+		Title:     "4",
+		Border:    "15",
+		Selected:  []string{"14"},
+		Text:      "0",
+		Highlight: "11",
+		Footer:    "15",
+		Output:    "15",
+		Muted:     "7",
+		Success:   "10",
+		Warning:   "11",
+		Error:     "9",
+		Info:      "14",
+		Syntax: Syntax{
+			Keyword:  "14",
+			String:   "10",
+			Variable: "11",
+			Operator: "13",
+			Comment:  "7",
+		},
+	},
+	// Attributes only, for terminals without colors or people who prefer none
+	"monochrome": {
+		Faint: true,
+	},
+}
+
+// Names returns the names of the built-in themes
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Animated reports whether the selected cell border cycles through colors
+func (t Theme) Animated() bool {
+	return len(t.Selected) > 1
+}
+
+// SelectedColor returns the border color of the selected cell at a step of the animation
+func (t Theme) SelectedColor(step int) lipgloss.Color {
+	if len(t.Selected) == 0 {
+		return ""
+	}
+	return lipgloss.Color(t.Selected[step%len(t.Selected)])
+}
+
+// Marked returns the style of text standing out on a background, reversed
+// when the theme has no background color for it
+func (t Theme) Marked(background string) lipgloss.Style {
+	if background == "" {
+		return lipgloss.NewStyle().Reverse(true)
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(t.Text)).Background(lipgloss.Color(background))
+}
+
+// Load returns a built-in theme, or reads a theme file given by path or by
+// name from dir
+func Load(name, dir string) (Theme, error) {
+	if t, ok := builtins[name]; ok {
+		return t, nil
+	}
+
+	path := name
+	if !strings.ContainsRune(name, filepath.Separator) && !strings.HasSuffix(name, ".toml") {
+		path = filepath.Join(dir, name+".toml")
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+
+	// The base theme is read first so that the file only overrides some colors
+	var base struct {
+		Base string `toml:"base"`
+	}
+	if _, err := toml.DecodeFile(path, &base); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Theme{}, fmt.Errorf("unknown theme %q, expected one of %s or a theme file", name, strings.Join(Names(), ", "))
+		}
+		return Theme{}, fmt.Errorf("theme %s: %v", path, err)
+	}
+	if base.Base == "" {
+		base.Base = "dark"
+	}
+	t, ok := builtins[base.Base]
+	if !ok {
+		return Theme{}, fmt.Errorf("theme %s: unknown base %q, expected one of %s", path, base.Base, strings.Join(Names(), ", "))
+	}
+
+	// The decoder writes into the slice it is given, which is the built-in one
+	t.Selected = slices.Clone(t.Selected)
+	meta, err := toml.DecodeFile(path, &t)
+	if err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %v", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return Theme{}, fmt.Errorf("theme %s: unknown color %q", path, undecoded[0].String())
+	}
+	if err := t.validate(); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %v", path, err)
+	}
+	return t, nil
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func (t Theme) validate() error {
+	colors := map[string]string{
+		"accent": t.Accent, "title": t.Title, "border": t.Border, "text": t.Text,
+		"highlight": t.Highlight, "footer": t.Footer, "output": t.Output,
+		"muted": t.Muted, "success": t.Success, "warning": t.Warning,
+		"error": t.Error, "info": t.Info,
+		"syntax.keyword": t.Syntax.Keyword, "syntax.string": t.Syntax.String,
+		"syntax.variable": t.Syntax.Variable, "syntax.operator": t.Syntax.Operator,
+		"syntax.comment": t.Syntax.Comment,
+	}
+	for i, color := range t.Selected {
+		colors[fmt.Sprintf("selected[%d]", i)] = color
+	}
+
+	names := make([]string, 0, len(colors))
+	for name := range colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !validColor(colors[name]) {
+			return fmt.Errorf("%s: invalid color %q, expected #RRGGBB or 0 to 255", name, colors[name])
+		}
+	}
+	return nil
+}
+
+func validColor(color string) bool {
+	if color == "" || hexColor.MatchString(color) {
+		return true
+	}
+	n, err := strconv.Atoi(color)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// Default returns the theme used without a config file
+func Default() Theme {
+	return builtins["dark"]
+}
@@ -0,0 +1,77 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeTheme(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name+".toml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLeavesBuiltinsUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	writeTheme(t, dir, "mine", "base = \"dark\"\nselected = [\"#000000\", \"#111111\"]\n")
+	before := slices.Clone(builtins["dark"].Selected)
+
+	mine, err := Load("mine", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(mine.Selected, []string{"#000000", "#111111"}) {
+		t.Errorf("Selected = %v, want the colors of the file", mine.Selected)
+	}
+	if !slices.Equal(builtins["dark"].Selected, before) {
+		t.Errorf("built-in dark Selected changed to %v", builtins["dark"].Selected)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeTheme(t, dir, "light-accent", "base = \"light\"\naccent = \"#123456\"\n")
+	writeTheme(t, dir, "bad-color", "error = \"red\"\n")
+	writeTheme(t, dir, "bad-base", "base = \"sepia\"\n")
+	writeTheme(t, dir, "unknown-key", "accnt = \"#123456\"\n")
+
+	tests := []struct {
+		name    string
+		wantErr bool
+		accent  string
+	}{
+		{name: "dark", accent: builtins["dark"].Accent},
+		{name: "monochrome", accent: ""},
+		{name: "light-accent", accent: "#123456"},
+		{name: filepath.Join(dir, "light-accent.toml"), accent: "#123456"},
+		{name: "bad-color", wantErr: true},
+		{name: "bad-base", wantErr: true},
+		{name: "unknown-key", wantErr: true},
+		{name: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Load(tt.name, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if err == nil && got.Accent != tt.accent {
+				t.Errorf("Accent = %q, want %q", got.Accent, tt.accent)
+			}
+		})
+	}
+}
+
+func TestValidColor(t *testing.T) {
+	for color, want := range map[string]bool{
+		"": true, "#FFF": true, "#a1b2c3": true, "0": true, "255": true,
+		"256": false, "-1": false, "#12345": false, "red": false,
+	} {
+		if got := validColor(color); got != want {
+			t.Errorf("validColor(%q) = %v, want %v", color, got, want)
+		}
+	}
+}
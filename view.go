@@ -4,29 +4,58 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// Colors come from the theme, see applyTheme
 var (
-	appNameStyle = lipgloss.NewStyle().Background(lipgloss.Color("99")).Padding(0, 1)
-	faintStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Faint(true)
+	appNameStyle = lipgloss.NewStyle().Padding(0, 1)
+	faintStyle   = lipgloss.NewStyle()
+	errorStyle   = lipgloss.NewStyle()
 
 	// Base style for textarea container
 	textareaStyle = lipgloss.NewStyle().
 			Padding(1, 1).
 			Margin(0, 0, 0, 0).
-			BorderStyle(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#E8E8E8"))
+			BorderStyle(lipgloss.RoundedBorder())
 
 	// Style for the label
 	textareaLabelStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#B19CD9")).
 				Width(5).
 				Align(lipgloss.Right).
 				MarginRight(1)
 )
 
 func (m Model) View() string {
-	s := appNameStyle.Render("Cahier") + "\n\n"
+	// The escapes take no room on the line, which never changes
+	s := appNameStyle.Render("Cahier") + m.escapeSequence + "\n\n"
 
-	s += m.cmdsHistory.View() + "\n\n"
+	if m.currentMode == PaletteMode {
+		if m.flash != "" {
+			return s + m.palette.View() + "\n\n" + m.flash
+		}
+		return s + m.palette.View() + "\n\n" +
+			faintStyle.Render(describeKeys(m.keys.Palette.ShortHelp()...))
+	}
+
+	if m.currentMode == PagerMode {
+		return s + m.pager.View() + "\n\n" +
+			faintStyle.Render("↑/↓/pgup/pgdown: Scroll - "+describeKeys(m.keys.Pager.ShortHelp()...))
+	}
+
+	if m.currentMode == ConfirmMode {
+		return s + m.confirm.View(m.width) + "\n\n" +
+			faintStyle.Render(describeKeys(m.keys.Confirm.ShortHelp()...))
+	}
+
+	if m.currentMode == RevisionsMode {
+		return s + m.revisions.View(m.width) + "\n\n" +
+			faintStyle.Render(describeKeys(m.keys.Revisions.ShortHelp()...))
+	}
+
+	if m.currentMode == HelpMode {
+		return s + m.help.viewport.View() + "\n\n" +
+			faintStyle.Render("↑/↓/pgup/pgdown: Scroll - "+describeKeys(m.keys.Help.Close))
+	}
+
+	s += viewCells(m) + "\n\n"
 
 	// Only show bottom textarea for new commands
 	if m.currentMode == NewCommandMode {
@@ -43,13 +72,40 @@ func (m Model) View() string {
 		) + "\n\n"
 	}
 
+	s += statusBar(m) + "\n"
+
+	k, quit := m.keys.View, m.keys.Global.Quit
 	switch m.currentMode {
 	case ViewMode:
-		s += faintStyle.Render("n: New cell - enter: Edit selected command - ctrl+d: Quit")
+		if m.flash != "" {
+			s += m.flash
+		} else if m.outputDiff != nil {
+			s += faintStyle.Render(describeKeys(k.OlderRun, k.NewerRun, relabel(k.Golden, "Pin/unpin latest as golden"), relabel(k.Diff, "Hide diff"), quit))
+		} else if m.search.query != "" {
+			s += faintStyle.Render(describeKeys(k.NextMatch, k.PrevMatch, relabel(k.Clear, "Clear search"), quit))
+		} else if !m.cmdsHistory.Filter().IsZero() {
+			s += faintStyle.Render("Filter: " + m.cmdsHistory.Filter().String() + " - " +
+				describeKeys(relabel(k.Filter, "Change filter"), relabel(k.Clear, "Clear filter"), quit))
+		} else {
+			s += faintStyle.Render(describeKeys(append(k.ShortHelp(), quit)...))
+		}
 	case EditMode:
-		s += faintStyle.Render("ctrl+r: Run - ctrl+s: Save - escape: Cancel - ctrl+d: Quit")
+		if m.flash != "" {
+			s += m.flash
+		} else {
+			s += faintStyle.Render(describeKeys(append(m.keys.Edit.ShortHelp(), quit)...))
+		}
 	case NewCommandMode:
-		s += faintStyle.Render("ctrl+r: Run - escape: Cancel - ctrl+d: Quit")
+		if m.flash != "" {
+			s += m.flash
+		} else {
+			s += faintStyle.Render(describeKeys(append(m.keys.NewCommand.ShortHelp(), quit)...))
+		}
+	case SearchMode, TagMode, FilterMode, AssertMode, NormalizeMode:
+		s += m.prompt.View()
+		if m.promptErr != "" {
+			s += "  " + errorStyle.Render(m.promptErr)
+		}
 	}
 
 	return s